@@ -0,0 +1,174 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Parse error codes returned by the user account endpoints
+const (
+	objectNotFound      = 101
+	invalidEmailAddress = 125
+	usernameMissing     = 200
+	passwordMissing     = 201
+	usernameTaken       = 202
+	emailTaken          = 203
+	emailMissing        = 204
+	emailNotFound       = 205
+)
+
+var (
+	// Returned when a username and password do not match any user
+	ErrInvalidCredentials = errors.New("parse: invalid username or password")
+
+	// Returned when a username is required but was not provided
+	ErrUsernameMissing = errors.New("parse: username is required")
+
+	// Returned when a password is required but was not provided
+	ErrPasswordMissing = errors.New("parse: password is required")
+
+	// Returned when an email address is required but was not provided
+	ErrEmailMissing = errors.New("parse: email address is required")
+
+	// Returned when an email address is not valid
+	ErrInvalidEmailAddress = errors.New("parse: invalid email address")
+
+	// Returned when no user has the given email address
+	ErrEmailNotFound = errors.New("parse: no user found with email address")
+
+	// Returned when a username is already in use by another user
+	ErrUsernameTaken = errors.New("parse: username has already been taken")
+
+	// Returned when an email address is already in use by another user
+	ErrEmailTaken = errors.New("parse: email address has already been taken")
+)
+
+// Converts API errors with codes returned by the user account endpoints to
+// the corresponding error value. Other errors are returned unchanged.
+func accountError(err error) error {
+	apiErr, ok := err.(APIError)
+	if !ok {
+		return err
+	}
+
+	switch apiErr.Code() {
+	case objectNotFound:
+		return ErrInvalidCredentials
+	case usernameMissing:
+		return ErrUsernameMissing
+	case passwordMissing:
+		return ErrPasswordMissing
+	case emailMissing:
+		return ErrEmailMissing
+	case invalidEmailAddress:
+		return ErrInvalidEmailAddress
+	case emailNotFound:
+		return ErrEmailNotFound
+	case usernameTaken:
+		return ErrUsernameTaken
+	case emailTaken:
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// Send a password reset email to the user with the given email address.
+// Returns ErrEmailNotFound if there is no such user.
+func (c *Client) RequestPasswordReset(email string) error {
+	if email == "" {
+		return ErrEmailMissing
+	}
+	_, err := c.doRequest(&emailRequest{ep: "requestPasswordReset", email: email})
+	return accountError(err)
+}
+
+// Resend the email verification email to the user with the given email
+// address. Returns ErrEmailNotFound if there is no such user.
+func (c *Client) RequestEmailVerification(email string) error {
+	if email == "" {
+		return ErrEmailMissing
+	}
+	_, err := c.doRequest(&emailRequest{ep: "verificationEmailRequest", email: email})
+	return accountError(err)
+}
+
+// Check that password is the password of the user with the given username,
+// without creating a session. Returns ErrInvalidCredentials if it is not.
+func (c *Client) VerifyPassword(username, password string) error {
+	if username == "" {
+		return ErrUsernameMissing
+	}
+	if password == "" {
+		return ErrPasswordMissing
+	}
+	_, err := c.doRequest(&verifyPasswordRequest{username: username, password: password})
+	return accountError(err)
+}
+
+type emailRequest struct {
+	ep    string
+	email string
+}
+
+func (e *emailRequest) method() string {
+	return "POST"
+}
+
+func (e *emailRequest) endpoint() (string, error) {
+	return e.ep, nil
+}
+
+func (e *emailRequest) body() (string, error) {
+	b, err := json.Marshal(map[string]string{"email": e.email})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (e *emailRequest) useMasterKey() bool {
+	return false
+}
+
+func (e *emailRequest) sessionToken() string {
+	return ""
+}
+
+func (e *emailRequest) contentType() string {
+	return "application/json"
+}
+
+type verifyPasswordRequest struct {
+	username string
+	password string
+}
+
+// Credentials are sent in the body, so that they never appear in request logs
+// or proxy access logs as part of the URL
+func (v *verifyPasswordRequest) method() string {
+	return "POST"
+}
+
+func (v *verifyPasswordRequest) endpoint() (string, error) {
+	return "verifyPassword", nil
+}
+
+func (v *verifyPasswordRequest) body() (string, error) {
+	b, err := json.Marshal(map[string]string{"username": v.username, "password": v.password})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (v *verifyPasswordRequest) useMasterKey() bool {
+	return false
+}
+
+func (v *verifyPasswordRequest) sessionToken() string {
+	return ""
+}
+
+func (v *verifyPasswordRequest) contentType() string {
+	return "application/json"
+}
@@ -0,0 +1,103 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/requestPasswordReset" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error decoding request body: %v", err)
+		}
+		switch body["email"] {
+		case "kylemcc@gmail.com":
+			fmt.Fprintf(w, `{}`)
+		case "invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":125,"error":"you must provide a valid email string"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":205,"error":"no user found with email %s"}`, body["email"])
+		}
+	})
+	defer teardownTestServer()
+
+	if err := testClient.RequestPasswordReset("kylemcc@gmail.com"); err != nil {
+		t.Errorf("unexpected error requesting password reset: %v", err)
+	}
+	if err := testClient.RequestPasswordReset("nobody@example.com"); err != ErrEmailNotFound {
+		t.Errorf("expected ErrEmailNotFound. got [%v]", err)
+	}
+	if err := testClient.RequestPasswordReset("invalid"); err != ErrInvalidEmailAddress {
+		t.Errorf("expected ErrInvalidEmailAddress. got [%v]", err)
+	}
+	if err := testClient.RequestPasswordReset(""); err != ErrEmailMissing {
+		t.Errorf("expected ErrEmailMissing. got [%v]", err)
+	}
+}
+
+func TestRequestEmailVerification(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/verificationEmailRequest" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["email"] != "kylemcc@gmail.com" {
+			t.Errorf("wrong email sent. got [%v] expected [%v]", body["email"], "kylemcc@gmail.com")
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":-1,"error":"Email kylemcc@gmail.com is already verified."}`)
+	})
+	defer teardownTestServer()
+
+	err := testClient.RequestEmailVerification("kylemcc@gmail.com")
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code() != -1 {
+		t.Errorf("expected an APIError with code -1. got [%v]", err)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/verifyPassword" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("expected credentials not to be sent in the URL. got [%s]", r.URL.RawQuery)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("wrong content type. got [%v] expected [%v]", ct, "application/json")
+		}
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error decoding request body: %v", err)
+		}
+		if body["username"] != "username" {
+			t.Errorf("wrong username sent. got [%v] expected [%v]", body["username"], "username")
+		}
+		if body["password"] == "password" {
+			fmt.Fprintf(w, `{"objectId":"user1","username":"username"}`)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"code":101,"error":"Invalid username/password."}`)
+		}
+	})
+	defer teardownTestServer()
+
+	if err := testClient.VerifyPassword("username", "password"); err != nil {
+		t.Errorf("unexpected error verifying password: %v", err)
+	}
+	if err := testClient.VerifyPassword("username", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials. got [%v]", err)
+	}
+	if err := testClient.VerifyPassword("username", ""); err != ErrPasswordMissing {
+		t.Errorf("expected ErrPasswordMissing. got [%v]", err)
+	}
+}
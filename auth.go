@@ -0,0 +1,120 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Log in with a third-party authentication provider, signing up a new user if
+// no user is linked to the given auth data. provider is the name of the
+// provider in Parse (e.g. "facebook", or the name of a custom auth adapter),
+// and authData is the data the provider expects, e.g.:
+//
+//	s, err := cli.LoginWith("linkedin", map[string]interface{}{
+//		"id":           "abc",
+//		"access_token": "xyz",
+//	}, nil)
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes, and will be accessible
+// by calling session.User().
+func (c *Client) LoginWith(provider string, authData map[string]interface{}, u interface{}) (Session, error) {
+	if provider == "" {
+		return nil, errors.New("parse: auth provider must not be empty")
+	}
+	return c.loginWithAuthData(&AuthData{Extra: map[string]interface{}{provider: authData}}, u)
+}
+
+// Log in with Sign in with Apple. See LoginWith.
+func (c *Client) LoginApple(authData *AppleAuthData, u interface{}) (Session, error) {
+	return c.loginWithAuthData(&AuthData{Apple: authData}, u)
+}
+
+// Log in with Google. See LoginWith.
+func (c *Client) LoginGoogle(authData *GoogleAuthData, u interface{}) (Session, error) {
+	return c.loginWithAuthData(&AuthData{Google: authData}, u)
+}
+
+// Log in with GitHub. See LoginWith.
+func (c *Client) LoginGitHub(authData *GitHubAuthData, u interface{}) (Session, error) {
+	return c.loginWithAuthData(&AuthData{GitHub: authData}, u)
+}
+
+// Log in as a new anonymous user, identified by a randomly generated Id. The
+// user may later be converted to a regular user with Session.UpgradeAnonymous.
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes, and will be accessible
+// by calling session.User().
+func (c *Client) LoginAnonymously(u interface{}) (Session, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	return c.loginWithAuthData(&AuthData{Anonymous: &AnonymousAuthData{Id: id}}, u)
+}
+
+func (c *Client) loginWithAuthData(authData *AuthData, u interface{}) (Session, error) {
+	var user interface{}
+
+	if u == nil {
+		user = &User{}
+	} else if err := validateUser(u); err != nil {
+		return nil, err
+	} else {
+		user = u
+	}
+
+	s := &session{user: user, client: c}
+	if b, err := c.doRequest(&loginRequest{authdata: authData}); err != nil {
+		return nil, err
+	} else if st, err := c.handleLoginResponse(b, s.user); err != nil {
+		return nil, err
+	} else {
+		s.sessionToken = st
+	}
+
+	return s, nil
+}
+
+// Link the existing user pointed to by u to a third-party authentication
+// provider, so that they may log in with LoginWith. See LoginWith for a
+// description of provider and authData. This requires the Master Key.
+func (c *Client) LinkWith(u interface{}, provider string, authData map[string]interface{}) error {
+	return c.setAuthData(u, provider, authData, nil)
+}
+
+// Remove the link between the existing user pointed to by u and a third-party
+// authentication provider. This requires the Master Key.
+func (c *Client) UnlinkWith(u interface{}, provider string) error {
+	return c.setAuthData(u, provider, nil, nil)
+}
+
+// Sets the auth data for provider on the user u, using the Master Key if s is
+// nil, or else s's session token
+func (c *Client) setAuthData(u interface{}, provider string, authData map[string]interface{}, s *session) error {
+	if err := validateUser(u); err != nil {
+		return err
+	}
+	if provider == "" {
+		return errors.New("parse: auth provider must not be empty")
+	}
+	if f := reflect.ValueOf(u).Elem().FieldByName("Id"); !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return errors.New("parse: user Id field must not be empty")
+	}
+
+	var up Update
+	if s != nil {
+		var err error
+		if up, err = s.NewUpdate(u); err != nil {
+			return err
+		}
+	} else {
+		up, _ = c.NewUpdate(u)
+		up.UseMasterKey()
+	}
+
+	// A nil authData is sent as null, which removes the provider
+	up.Set("authData", map[string]interface{}{provider: authData})
+	return up.Execute()
+}
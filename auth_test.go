@@ -0,0 +1,259 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestLoginWith(t *testing.T) {
+	var body string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/users" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprintf(w, `{"objectId":"user1","sessionToken":"abcd","username":"kylemcc"}`)
+	})
+	defer teardownTestServer()
+
+	s, err := testClient.LoginWith("linkedin", map[string]interface{}{"id": "abc", "access_token": "xyz"}, nil)
+	if err != nil {
+		t.Errorf("unexpected error on login: %v", err)
+		t.FailNow()
+	}
+	if expected := `{"authData":{"linkedin":{"access_token":"xyz","id":"abc"}}}`; body != expected {
+		t.Errorf("wrong request body. got [%v] expected [%v]", body, expected)
+	}
+	if st := s.(*session).sessionToken; st != "abcd" {
+		t.Errorf("login did not set a proper session token. got [%v] expected [%v]", st, "abcd")
+	}
+	if u := s.User().(*User); u.Id != "user1" {
+		t.Errorf("login did not populate user. got [%+v]", u)
+	}
+}
+
+func TestLoginWithProviderHelpers(t *testing.T) {
+	var body string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprintf(w, `{"objectId":"user1","sessionToken":"abcd"}`)
+	})
+	defer teardownTestServer()
+
+	testCases := []struct {
+		login    func() (Session, error)
+		expected string
+	}{
+		{
+			func() (Session, error) { return testClient.LoginApple(&AppleAuthData{Id: "a", Token: "t"}, nil) },
+			`{"authData":{"apple":{"id":"a","token":"t"}}}`,
+		},
+		{
+			func() (Session, error) { return testClient.LoginGoogle(&GoogleAuthData{Id: "g", IdToken: "t"}, nil) },
+			`{"authData":{"google":{"id":"g","id_token":"t"}}}`,
+		},
+		{
+			func() (Session, error) {
+				return testClient.LoginGitHub(&GitHubAuthData{Id: "h", AccessToken: "t"}, nil)
+			},
+			`{"authData":{"github":{"id":"h","access_token":"t"}}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		if _, err := tc.login(); err != nil {
+			t.Errorf("unexpected error on login: %v", err)
+		}
+		if body != tc.expected {
+			t.Errorf("wrong request body. got [%v] expected [%v]", body, tc.expected)
+		}
+	}
+}
+
+func TestLinkWithAndUnlinkWith(t *testing.T) {
+	var body string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/1/users/user1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "master_key" {
+			t.Errorf("request did not have Master Key header set!")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	u := &User{Base: Base{Id: "user1"}}
+	if err := testClient.LinkWith(u, "github", map[string]interface{}{"id": "h", "access_token": "t"}); err != nil {
+		t.Errorf("unexpected error linking user: %v", err)
+	}
+	if expected := `{"authData":{"github":{"access_token":"t","id":"h"}}}`; body != expected {
+		t.Errorf("wrong link request body. got [%v] expected [%v]", body, expected)
+	}
+
+	if err := testClient.UnlinkWith(u, "github"); err != nil {
+		t.Errorf("unexpected error unlinking user: %v", err)
+	}
+	if expected := `{"authData":{"github":null}}`; body != expected {
+		t.Errorf("wrong unlink request body. got [%v] expected [%v]", body, expected)
+	}
+
+	if err := testClient.LinkWith(&User{}, "github", nil); err == nil {
+		t.Errorf("expected an error linking a user without an Id")
+	}
+}
+
+func TestLinkWithMergesAuthData(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	type userWithAuthData struct {
+		User
+		AuthData *AuthData
+	}
+
+	u := &userWithAuthData{AuthData: &AuthData{Facebook: &FacebookAuthData{Id: "fb"}}}
+	u.Id = "user1"
+	if err := testClient.LinkWith(u, "google", map[string]interface{}{"id": "g", "id_token": "t"}); err != nil {
+		t.Fatalf("unexpected error linking user: %v", err)
+	}
+	expected := &AuthData{
+		Facebook: &FacebookAuthData{Id: "fb"},
+		Google:   &GoogleAuthData{Id: "g", IdToken: "t"},
+	}
+	if !reflect.DeepEqual(u.AuthData, expected) {
+		t.Errorf("expected linked provider to be added to auth data. got [%+v] expected [%+v]", u.AuthData, expected)
+	}
+
+	if err := testClient.UnlinkWith(u, "facebook"); err != nil {
+		t.Fatalf("unexpected error unlinking user: %v", err)
+	}
+	expected = &AuthData{Google: &GoogleAuthData{Id: "g", IdToken: "t"}}
+	if !reflect.DeepEqual(u.AuthData, expected) {
+		t.Errorf("expected only the unlinked provider to be removed. got [%+v] expected [%+v]", u.AuthData, expected)
+	}
+}
+
+func TestAuthDataRoundTrip(t *testing.T) {
+	type userWithAuthData struct {
+		User
+		AuthData AuthData
+	}
+
+	src := map[string]interface{}{}
+	data := `{"authData":{"github":{"id":"h","access_token":"t"},"linkedin":{"id":"abc","access_token":"xyz"},"anonymous":{"id":"anon"}}}`
+	if err := json.Unmarshal([]byte(data), &src); err != nil {
+		t.Fatal(err)
+	}
+
+	u := userWithAuthData{}
+	if err := testClient.populateValue(&u, src); err != nil {
+		t.Errorf("unexpected error populating user: %v", err)
+		t.FailNow()
+	}
+
+	expected := AuthData{
+		Anonymous: &AnonymousAuthData{Id: "anon"},
+		GitHub:    &GitHubAuthData{Id: "h", AccessToken: "t"},
+		Extra: map[string]interface{}{
+			"linkedin": map[string]interface{}{"id": "abc", "access_token": "xyz"},
+		},
+	}
+	if !reflect.DeepEqual(u.AuthData, expected) {
+		t.Errorf("wrong auth data. got [%+v] expected [%+v]", u.AuthData, expected)
+	}
+
+	b, err := json.Marshal(u.AuthData)
+	if err != nil {
+		t.Errorf("unexpected error marshaling auth data: %v", err)
+	}
+	if expected := `{"anonymous":{"id":"anon"},"github":{"access_token":"t","id":"h"},"linkedin":{"access_token":"xyz","id":"abc"}}`; string(b) != expected {
+		t.Errorf("wrong marshaled auth data. got [%s] expected [%s]", b, expected)
+	}
+}
+
+func TestLoginAnonymously(t *testing.T) {
+	var body map[string]map[string]map[string]string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/users" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprintf(w, `{"objectId":"user1","sessionToken":"abcd","username":"generated"}`)
+	})
+	defer teardownTestServer()
+
+	s, err := testClient.LoginAnonymously(nil)
+	if err != nil {
+		t.Errorf("unexpected error on anonymous login: %v", err)
+		t.FailNow()
+	}
+
+	id := body["authData"]["anonymous"]["id"]
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("anonymous id is not a UUID: [%v]", id)
+	}
+	if st := s.(*session).sessionToken; st != "abcd" {
+		t.Errorf("login did not set a proper session token. got [%v] expected [%v]", st, "abcd")
+	}
+}
+
+func TestUpgradeAnonymous(t *testing.T) {
+	var body map[string]interface{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/1/users/user1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "abcd" {
+			t.Errorf("request had wrong session token. got [%v] expected [%v]", h, "abcd")
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["username"] == "taken" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":202,"error":"Account already exists for this username."}`)
+			return
+		}
+		fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	user := &CustomUser{User: User{Base: Base{Id: "user1"}, Username: "generated"}, City: "Chicago"}
+	s := &session{client: testClient, user: user, sessionToken: "abcd"}
+
+	if err := s.UpgradeAnonymous("taken", "password", ""); err != ErrUsernameTaken {
+		t.Errorf("expected ErrUsernameTaken. got [%v]", err)
+	}
+
+	if err := s.UpgradeAnonymous("kylemcc", "password", "kylemcc@gmail.com"); err != nil {
+		t.Errorf("unexpected error upgrading anonymous user: %v", err)
+		t.FailNow()
+	}
+
+	expected := map[string]interface{}{
+		"username": "kylemcc",
+		"password": "password",
+		"email":    "kylemcc@gmail.com",
+		"authData": map[string]interface{}{"anonymous": nil},
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("wrong request body. got [%v] expected [%v]", body, expected)
+	}
+	if user.Id != "user1" || user.Username != "kylemcc" || user.Email != "kylemcc@gmail.com" || user.City != "Chicago" {
+		t.Errorf("user was not upgraded in place. got [%+v]", user)
+	}
+	if st := s.sessionToken; st != "abcd" {
+		t.Errorf("upgrade changed the session token. got [%v]", st)
+	}
+}
@@ -0,0 +1,262 @@
+package parse
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An interface for storing the raw responses of queries. Implementations
+// must be safe for concurrent use.
+//
+// Get returns the value stored under key, and whether it was present and
+// has not yet expired.
+//
+// Set stores value under key. A ttl of 0 means the value never expires.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+type queryCache struct {
+	store Cache
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+	gens map[string]uint64
+}
+
+// Enable caching of query results using the provided Cache. The results of
+// Get, Find, First and Count are cached for the duration ttl, unless
+// overridden for a class with SetCacheTTL. As with Cache.Set, a ttl of 0
+// means cached results never expire. A negative ttl disables caching for
+// classes without their own TTL.
+//
+// Results are cached per class, query, and credentials (session token or
+// master key), so users never see results fetched on behalf of someone else.
+// Creating, updating or deleting an object through this client invalidates
+// all cached results for that object's class. Changes made elsewhere are
+// not detected, and will only be seen once cached results expire.
+//
+// Pass a nil Cache to disable caching.
+func (c *Client) SetCache(cache Cache, ttl time.Duration) {
+	if cache == nil {
+		c.cache = nil
+		return
+	}
+	c.cache = &queryCache{
+		store: cache,
+		ttl:   ttl,
+		ttls:  map[string]time.Duration{},
+		gens:  map[string]uint64{},
+	}
+}
+
+// Override the cache TTL for the class named className. A ttl of 0 means
+// results for the class never expire, and a negative ttl disables caching for
+// the class. This is a no-op if caching has not been enabled
+// with SetCache.
+func (c *Client) SetCacheTTL(className string, ttl time.Duration) {
+	if c.cache == nil {
+		return
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	c.cache.ttls[className] = ttl
+}
+
+// Executes the query request q, consulting the cache first if enabled, and
+// passes the response body to decode
+func (c *Client) doQueryRequest(q *query, decode func(r io.Reader) error) error {
+	if c.cache == nil {
+		return c.doRequestDecode(q, decode)
+	}
+
+	// A logged out session must not be served results cached for other
+	// credentials
+	if q.sess != nil {
+		if _, err := q.sess.token(); err != nil {
+			return err
+		}
+	}
+
+	key, ttl, err := c.cache.key(q)
+	if err != nil || ttl < 0 {
+		return c.doRequestDecode(q, decode)
+	}
+
+	// Cached results must be held in memory in full, so they are not
+	// decoded as they are read
+	if b, ok := c.cache.store.Get(key); ok {
+		return decode(bytes.NewReader(b))
+	}
+
+	b, err := c.doRequest(q)
+	if err != nil {
+		return err
+	}
+	c.cache.store.Set(key, b, ttl)
+	return decode(bytes.NewReader(b))
+}
+
+// Invalidate any cached query results for the class of v
+func (c *Client) invalidateCache(v interface{}) {
+	if c.cache != nil {
+		c.cache.invalidate(getElemClassName(v))
+	}
+}
+
+func (qc *queryCache) invalidate(className string) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.gens[className]++
+}
+
+// Returns the cache key and ttl for the query q.
+//
+// Keys include a per-class generation number. Invalidating a class bumps its
+// generation, so stale entries are never read again and simply age out of
+// the underlying store.
+func (qc *queryCache) key(q *query) (string, time.Duration, error) {
+	className := getElemClassName(q.inst)
+
+	qc.mu.Lock()
+	ttl, ok := qc.ttls[className]
+	if !ok {
+		ttl = qc.ttl
+	}
+	gen := qc.gens[className]
+	qc.mu.Unlock()
+
+	ck, err := q.canonicalForm()
+	if err != nil {
+		return "", 0, err
+	}
+
+	sum := sha1.Sum([]byte(q.client.authIdentity(q) + "\n" + ck))
+	return fmt.Sprintf("%s:%d:%x", className, gen, sum), ttl, nil
+}
+
+// Returns a representation of q which is identical for any two queries that
+// would produce the same request, regardless of the order in which options
+// were specified
+func (q *query) canonicalForm() (string, error) {
+	w, err := json.Marshal(q.where)
+	if err != nil {
+		return "", err
+	}
+
+	sortedKeys := func(m map[string]struct{}) string {
+		ks := make([]string, 0, len(m))
+		for k := range m {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		return strings.Join(ks, ",")
+	}
+
+	intOrEmpty := func(i *int) string {
+		if i == nil {
+			return ""
+		}
+		return strconv.Itoa(*i)
+	}
+
+	var id string
+	if q.op == otGet && q.instId != nil {
+		id = *q.instId
+	}
+
+	parts := []string{
+		getEndpointBase(q.inst),
+		strconv.Itoa(int(q.op)),
+		id,
+		string(w),
+		strings.Join(q.orderBy, ","),
+		sortedKeys(q.keys),
+		sortedKeys(q.include),
+		intOrEmpty(q.limit),
+		intOrEmpty(q.skip),
+		intOrEmpty(q.count),
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Create an in-memory Cache holding at most size entries. Once full, the
+// least recently used entry is evicted to make room for new entries. A size
+// of 0 means the cache is unbounded.
+func NewLRUCache(size int) Cache {
+	return &lruCache{
+		size:    size,
+		ll:      list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (l *lruCache) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	ent := e.Value.(*lruEntry)
+	if !ent.expires.IsZero() && time.Now().After(ent.expires) {
+		l.remove(e)
+		return nil, false
+	}
+	l.ll.MoveToFront(e)
+	return ent.value, true
+}
+
+func (l *lruCache) Set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if e, ok := l.entries[key]; ok {
+		ent := e.Value.(*lruEntry)
+		ent.value = value
+		ent.expires = expires
+		l.ll.MoveToFront(e)
+		return
+	}
+
+	l.entries[key] = l.ll.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for l.size > 0 && l.ll.Len() > l.size {
+		l.remove(l.ll.Back())
+	}
+}
+
+func (l *lruCache) remove(e *list.Element) {
+	l.ll.Remove(e)
+	delete(l.entries, e.Value.(*lruEntry).key)
+}
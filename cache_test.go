@@ -0,0 +1,136 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+
+	// touch "a" so that "b" becomes the least recently used entry
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("expected key [a] to be present")
+	}
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected key [b] to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected key [%s] to be present", k)
+		}
+	}
+}
+
+func TestLRUCacheExpiration(t *testing.T) {
+	c := NewLRUCache(0)
+	c.Set("a", []byte("1"), time.Millisecond)
+	c.Set("b", []byte("2"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected key [a] to be expired")
+	}
+	if v, ok := c.Get("b"); !ok || string(v) != "2" {
+		t.Errorf("expected key [b] to be present. got [%s]", v)
+	}
+}
+
+func TestQueryCanonicalFormIgnoresOrdering(t *testing.T) {
+	q1, _ := testClient.NewQuery(&User{})
+	q1.Include("a", "b", "c")
+	q1.Keys("x", "y")
+	q1.EqualTo("f1", "v1")
+	q1.EqualTo("f2", "v2")
+
+	q2, _ := testClient.NewQuery(&User{})
+	q2.Keys("y", "x")
+	q2.EqualTo("f2", "v2")
+	q2.Include("c", "b", "a")
+	q2.EqualTo("f1", "v1")
+
+	c1, err := q1.(*query).canonicalForm()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c2, _ := q2.(*query).canonicalForm()
+	if c1 != c2 {
+		t.Errorf("expected equivalent queries to have the same canonical form. got:\n%s\n\nand:\n%s", c1, c2)
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `{"objectId":"abc","username":"kylemcc","createdAt":"2014-12-20T18:31:19.123Z"}`)
+		case "PUT":
+			fmt.Fprintf(w, `{"updatedAt":"2014-12-20T18:31:19.123Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	testClient.SetCache(NewLRUCache(10), time.Minute)
+	defer testClient.SetCache(nil, 0)
+
+	get := func(st string) *User {
+		u := User{}
+		q, _ := testClient.NewQuery(&u)
+		q.SetSessionToken(st)
+		if err := q.Get("abc"); err != nil {
+			t.Fatalf("unexpected error on get: %v", err)
+		}
+		return &u
+	}
+
+	if u := get(""); u.Id != "abc" || u.Username != "kylemcc" {
+		t.Errorf("unexpected user: %+v", u)
+	}
+	if u := get(""); u.Id != "abc" || u.Username != "kylemcc" {
+		t.Errorf("unexpected cached user: %+v", u)
+	}
+	if requests != 1 {
+		t.Errorf("expected cached query to not make a request. got [%d] requests", requests)
+	}
+
+	get("session_token")
+	if requests != 2 {
+		t.Errorf("expected query with different credentials to make a request. got [%d] requests", requests)
+	}
+
+	upd, _ := testClient.NewUpdate(&User{Base: Base{Id: "abc"}})
+	upd.Set("username", "kylemcc2")
+	if err := upd.Execute(); err != nil {
+		t.Fatalf("unexpected error on update: %v", err)
+	}
+
+	get("")
+	if requests != 4 {
+		t.Errorf("expected update to invalidate cached results. got [%d] requests", requests)
+	}
+
+	testClient.SetCacheTTL("_User", -1)
+	get("")
+	if requests != 5 {
+		t.Errorf("expected query to bypass cache for disabled class. got [%d] requests", requests)
+	}
+
+	// A ttl of 0 caches results with no expiry
+	testClient.SetCacheTTL("_User", 0)
+	if err := upd.Execute(); err != nil {
+		t.Fatalf("unexpected error on update: %v", err)
+	}
+	get("")
+	get("")
+	if requests != 7 {
+		t.Errorf("expected a ttl of 0 to cache results. got [%d] requests", requests)
+	}
+}
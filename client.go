@@ -1,7 +1,9 @@
 package parse
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -20,10 +22,10 @@ const (
 	MasterKeyHeader    = "X-Parse-Master-Key"
 	SessionTokenHeader = "X-Parse-Session-Token"
 	UserAgentHeader    = "User-Agent"
-)
 
-var fieldNameCache map[reflect.Type]map[string]string = make(map[reflect.Type]map[string]string)
-var fieldCache = make(map[reflect.Type]reflect.StructField)
+	RevocableSessionHeader = "X-Parse-Revocable-Session"
+	InstallationIdHeader   = "X-Parse-Installation-Id"
+)
 
 type request interface {
 	method() string
@@ -35,6 +37,10 @@ type request interface {
 }
 
 // A Client manages communication with the Parse server.
+//
+// A Client is safe for concurrent use by multiple goroutines. Methods that
+// configure the client (e.g. SetRateLimit, SetCache or Use) should be called
+// before the client is shared, and not while requests are in progress.
 type Client struct {
 	appId     string
 	restKey   string
@@ -43,8 +49,22 @@ type Client struct {
 	path      string
 	userAgent string
 
-	httpClient *http.Client
-	limiter    limiter
+	httpClient  *http.Client
+	limiter     limiter
+	ownsLimiter bool
+	concurrency limiter
+	cache       *queryCache
+	flights     *flightGroup
+	middleware  []Middleware
+	tracer      Tracer
+	metrics     Metrics
+	logger      *requestLogger
+	types       *typeRegistry
+
+	strictDecoding    bool
+	tracker           *Tracker
+	revocableSessions bool
+	headers           RequestHeaders
 }
 
 // Create the parse client with your API keys
@@ -57,6 +77,7 @@ func NewClient(appId, restKey, masterKey, host, path string) *Client {
 		path:       path,
 		userAgent:  "github.com/kylemcc/parse",
 		httpClient: &http.Client{},
+		types:      newTypeRegistry(registeredTypes),
 	}
 }
 
@@ -75,16 +96,149 @@ func (c *Client) SetUserAgent(ua string) {
 //
 // If this option is set, this library will restrict calling code to
 // a maximum number of requests per second. Requests exceeding this limit
-// will block for the appropriate period of time.
+// will block for the appropriate period of time. A limit of 0 disables
+// rate limiting.
+//
+// Any requests blocked by a limiter previously configured with this method
+// are released, unless the limiter is still used by a copy of this client made
+// with WithHeaders. Use SetRateLimiter for fractional rates, or to share a
+// limiter between clients.
 func (c *Client) SetRateLimit(limit, burst uint) {
-	c.limiter = newRateLimiter(limit, burst)
+	if old, ok := c.limiter.(*RateLimiter); ok && c.ownsLimiter {
+		old.mu.Lock()
+		shared := old.shared
+		old.mu.Unlock()
+		if !shared {
+			old.Stop()
+		}
+	}
+	if limit == 0 {
+		c.limiter = nil
+	} else {
+		c.limiter = NewRateLimiter(float64(limit), int(burst))
+	}
+	c.ownsLimiter = c.limiter != nil
+}
+
+// Set the RateLimiter used to restrict the rate of requests made by this
+// client. Pass nil to disable rate limiting.
+func (c *Client) SetRateLimiter(r *RateLimiter) {
+	if r == nil {
+		c.limiter = nil
+	} else {
+		c.limiter = r
+	}
+	c.ownsLimiter = false
 }
 
 func (c *Client) SetHTTPClient(hc *http.Client) {
 	c.httpClient = hc
 }
 
+// Returns a string identifying the credentials op will be sent with. This
+// includes every optional header, since keys and the installation Id may
+// change what Parse authorizes or returns.
+func (c *Client) authIdentity(op request) string {
+	var id string
+	if st := op.sessionToken(); st != "" {
+		id = "st:" + st
+	} else if op.useMasterKey() && c.masterKey != "" {
+		id = "master"
+	}
+	for _, kv := range c.headersFor(op).pairs() {
+		if kv[1] != "" {
+			id += "\n" + kv[0] + ":" + kv[1]
+		}
+	}
+	return id
+}
+
 func (c *Client) doRequest(op request) ([]byte, error) {
+	if sb, ok := op.(sessionBound); ok {
+		if s := sb.boundSession(); s != nil {
+			return c.doSessionRequest(op, s)
+		}
+	}
+	return c.doRequestOnce(op)
+}
+
+// Executes op as doRequest does, passing the body of a successful response to
+// decode. If no middleware, logger, instrumentation or request coalescing
+// needs the raw response, the body is decoded as it is read from the
+// connection rather than being read into memory first.
+func (c *Client) doRequestDecode(op request, decode func(r io.Reader) error) error {
+	if len(c.middleware) > 0 || c.logger != nil || c.tracer != nil || c.metrics != nil || c.flights != nil {
+		b, err := c.doRequest(op)
+		if err != nil {
+			return err
+		}
+		return decode(bytes.NewReader(b))
+	}
+
+	send := func() error {
+		return c.stream(op, decode)
+	}
+	if sb, ok := op.(sessionBound); ok {
+		if s := sb.boundSession(); s != nil {
+			return c.retrySession(op, s, send)
+		}
+	}
+	return send()
+}
+
+func (c *Client) doRequestOnce(op request) ([]byte, error) {
+	if c.flights != nil && op.method() == "GET" {
+		if ep, err := op.endpoint(); err == nil {
+			key := op.method() + " " + ep + "\n" + c.authIdentity(op)
+			return c.flights.do(key, func() ([]byte, error) {
+				return c.execute(op)
+			})
+		}
+	}
+	return c.execute(op)
+}
+
+func (c *Client) execute(op request) ([]byte, error) {
+	o, err := c.operation(op)
+	if err != nil {
+		return nil, err
+	}
+
+	h := c.send
+	if c.logger != nil {
+		h = c.logger.wrap(h)
+	}
+	if c.tracer != nil || c.metrics != nil {
+		h = c.instrument(h)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+
+	resp, err := h(o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Error formats are consistent. If the response is an error,
+	// return a APIError
+	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return nil, responseError(resp.Body)
+	}
+	return resp.Body, nil
+}
+
+// Returns the APIError described by the body of an error response
+func responseError(body []byte) error {
+	apiErr := apiError{}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return err
+	}
+	return &apiErr
+}
+
+// Describes the request op as an Operation
+func (c *Client) operation(op request) (*Operation, error) {
 	ep, err := op.endpoint()
 	if err != nil {
 		return nil, err
@@ -98,42 +252,142 @@ func (c *Client) doRequest(op request) ([]byte, error) {
 	}
 
 	method := op.method()
-	var body io.Reader
+	var body string
 	if method == "POST" || method == "PUT" {
-		b, err := op.body()
-		if err != nil {
+		if body, err = op.body(); err != nil {
 			return nil, err
 		}
-		body = strings.NewReader(b)
 	}
 
-	req, err := http.NewRequest(method, u.String(), body)
-	if err != nil {
-		return nil, err
+	name, className := describe(op)
+	o := &Operation{
+		Name:            name,
+		ClassName:       className,
+		Method:          method,
+		Endpoint:        ep,
+		URL:             u,
+		Header:          http.Header{},
+		Body:            body,
+		UseMasterKey:    op.useMasterKey() && c.masterKey != "" && op.sessionToken() == "",
+		HasSessionToken: op.sessionToken() != "",
+		Context:         context.Background(),
 	}
 
-	req.Header.Add(UserAgentHeader, c.userAgent)
-	req.Header.Add(AppIdHeader, c.appId)
-	if op.useMasterKey() && c.masterKey != "" && op.sessionToken() == "" {
-		req.Header.Add(MasterKeyHeader, c.masterKey)
+	o.Header.Add(UserAgentHeader, c.userAgent)
+	o.Header.Add(AppIdHeader, c.appId)
+	if o.UseMasterKey {
+		o.Header.Add(MasterKeyHeader, c.masterKey)
 	} else {
-		req.Header.Add(RestKeyHeader, c.restKey)
+		o.Header.Add(RestKeyHeader, c.restKey)
 		if st := op.sessionToken(); st != "" {
-			req.Header.Add(SessionTokenHeader, st)
+			o.Header.Add(SessionTokenHeader, st)
 		}
 	}
-	if c := op.contentType(); c != "" {
-		req.Header.Add("Content-Type", op.contentType())
+	c.headersFor(op).write(o.Header)
+	if c.revocableSessions && createsSession(op) {
+		o.Header.Add(RevocableSessionHeader, "1")
+	}
+	if ct := op.contentType(); ct != "" {
+		o.Header.Add("Content-Type", ct)
+	}
+	o.Header.Add("Accept-Encoding", "gzip")
+	return o, nil
+}
+
+// Sends the request described by o to Parse. This is the innermost Handler
+// of the middleware chain.
+func (c *Client) send(o *Operation) (*Response, error) {
+	var out *Response
+	err := c.roundTrip(o, func(resp *http.Response, body io.Reader) ([]byte, error) {
+		// Size the buffer up front when the length is known, to avoid
+		// copying large responses as the buffer grows
+		buf := &bytes.Buffer{}
+		if resp.ContentLength > 0 && resp.Header.Get("Content-Encoding") != "gzip" {
+			buf.Grow(int(resp.ContentLength) + bytes.MinRead)
+		}
+		if _, err := buf.ReadFrom(body); err != nil {
+			return nil, err
+		}
+
+		out = &Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       buf.Bytes(),
+		}
+		return out.Body, nil
+	})
+	return out, err
+}
+
+// Sends op to Parse, decoding a successful response with decode as it is read
+// from the connection. The body is never held in memory in full. This
+// bypasses the middleware chain, so it may only be used when there is none.
+func (c *Client) stream(op request, decode func(r io.Reader) error) error {
+	o, err := c.operation(op)
+	if err != nil {
+		return err
+	}
+
+	return c.roundTrip(o, func(resp *http.Response, body io.Reader) ([]byte, error) {
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil, decode(body)
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return b, responseError(b)
+	})
+}
+
+// Sends the request described by o to Parse once the rate and concurrency
+// limiters allow, and calls read with the response and its decompressed body.
+// read returns the portion of the body it has read into memory, if any, with
+// which the limiters are notified of the response.
+func (c *Client) roundTrip(o *Operation, read func(resp *http.Response, body io.Reader) ([]byte, error)) error {
+	var body io.Reader
+	if o.Method == "POST" || o.Method == "PUT" {
+		body = strings.NewReader(o.Body)
 	}
-	req.Header.Add("Accept-Encoding", "gzip")
 
+	ctx := o.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, o.Method, o.URL.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header = o.Header
+
+	// Limiters are notified of the response once the request completes
+	var status int
+	var respBody []byte
+
+	// Wait on the rate limiter before taking a concurrency slot, so that
+	// requests waiting for a token don't hold slots other requests could use
 	if c.limiter != nil {
-		c.limiter.limit()
+		done, waited, err := c.limiter.wait(ctx, o)
+		if err != nil {
+			return err
+		}
+		defer func() { done(status, respBody) }()
+		if c.metrics != nil && waited > 0 {
+			c.metrics.ObserveRateLimitWait(o, waited)
+		}
+	}
+
+	if c.concurrency != nil {
+		done, _, err := c.concurrency.wait(ctx, o)
+		if err != nil {
+			return err
+		}
+		defer func() { done(status, respBody) }()
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer resp.Body.Close()
@@ -141,7 +395,7 @@ func (c *Client) doRequest(op request) ([]byte, error) {
 	switch resp.Header.Get("Content-Encoding") {
 	case "gzip":
 		if r, err := gzip.NewReader(resp.Body); err != nil {
-			return nil, err
+			return err
 		} else {
 			reader = r
 		}
@@ -149,102 +403,187 @@ func (c *Client) doRequest(op request) ([]byte, error) {
 		reader = resp.Body
 	}
 
-	respBody, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, err
-	}
+	status = resp.StatusCode
+	respBody, err = read(resp, reader)
 
-	// Error formats are consistent. If the response is an error,
-	// return a APIError
-	if !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
-		apiErr := apiError{}
-		if err := json.Unmarshal(respBody, &apiErr); err != nil {
-			return nil, err
-		}
-		return nil, &apiErr
+	// Drain anything left unread, e.g. after a decoding error, so that the
+	// connection may be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+// Holds the state used to populate values from Parse responses
+type decoder struct {
+	types *typeRegistry
+
+	// Whether problems with the response are reported as a DecodeError
+	strict bool
+
+	// Whether the response may omit fields, e.g. because only selected keys
+	// were requested. Required fields are not checked if so.
+	partial bool
+
+	// JSON path of the value currently being populated
+	path string
+
+	// Problems found so far in strict mode
+	problems *DecodeError
+}
+
+func (c *Client) decoder() *decoder {
+	d := &decoder{types: c.types}
+	if d.types == nil {
+		d.types = registeredTypes
 	}
-	return respBody, nil
+	return d
+}
+
+func (c *Client) handleResponse(body []byte, dst interface{}) error {
+	return c.decoder().handleResponse(body, dst)
+}
+
+func (c *Client) populateValue(dst interface{}, src interface{}) error {
+	return c.decoder().populateValue(dst, src)
 }
 
-func handleResponse(body []byte, dst interface{}) error {
+func (d *decoder) handleResponse(body []byte, dst interface{}) error {
+	return d.decodeResponse(bytes.NewReader(body), dst)
+}
+
+// Populates dst from the response body read from r
+func (d *decoder) decodeResponse(r io.Reader, dst interface{}) error {
 	rv := reflect.ValueOf(dst)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return fmt.Errorf("parse: expected a non-nil pointer got %v", rv.Kind())
 	}
 
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return fmt.Errorf("parse: expected a JSON object, got %v", t)
+	}
+
 	data := make(map[string]interface{})
-	if err := json.Unmarshal(body, &data); err != nil {
+	streamed, n := false, 0
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		k, _ := t.(string)
+
+		// Query results are decoded one at a time directly into the
+		// destination slice, so that only a single result is held in
+		// its generic form at any time
+		if k == "results" && reflect.Indirect(rv).Kind() == reflect.Slice {
+			if t, err = dec.Token(); err != nil {
+				return err
+			} else if t == json.Delim('[') {
+				if n, err = d.decodeResults(dec, rv); err != nil {
+					return err
+				}
+				streamed = true
+				continue
+			} else if t != nil {
+				return fmt.Errorf("parse: expected slice, got %v", t)
+			}
+			data[k] = nil
+			continue
+		}
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		data[k] = v
+	}
+	if _, err := dec.Token(); err != nil {
 		return err
 	}
 
 	if c, ok := data["count"]; ok {
-		return populateValue(dst, c)
+		return d.finish(d.populateValue(dst, c))
+	} else if streamed {
+		if n == 0 {
+			return ErrNoRows
+		}
+		return d.finish(nil)
 	} else if r, ok := data["results"]; ok {
 		if rl, ok := r.([]interface{}); ok && len(rl) == 0 {
 			return ErrNoRows
 		}
 
 		// Handle query results
-		return populateValue(dst, r)
+		d.path = "results"
+		return d.finish(d.populateValue(dst, r))
 	} else {
-		return populateValue(dst, data)
+		return d.finish(d.populateValue(dst, data))
 	}
 }
 
-func getFields(t reflect.Type) []reflect.StructField {
-	fields := make([]reflect.StructField, 0)
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+// Decodes the elements of a JSON array into the slice pointed to by dst,
+// replacing its contents. The opening bracket must already have been read
+// from dec. Returns the number of elements decoded.
+func (d *decoder) decodeResults(dec *json.Decoder, dst reflect.Value) (int, error) {
+	dvi := dst.Elem()
+	dt := dvi.Type().Elem()
+	et := dt
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		ft := f.Type
-		if ft.Kind() == reflect.Ptr {
-			ft = ft.Elem()
+	dvi.Set(reflect.MakeSlice(dvi.Type(), 0, dvi.Cap()))
+	for i := 0; dec.More(); i++ {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return 0, err
 		}
-		switch ft.Kind() {
-		case reflect.Struct:
-			if f.Anonymous {
-				fields = append(fields, getFields(ft)...)
-			} else if len(f.PkgPath) == 0 {
-				fields = append(fields, f)
-			}
-		default:
-			if len(f.PkgPath) == 0 {
-				fields = append(fields, f)
-			}
+
+		newV := reflect.New(et)
+		prev := d.enterIndex("results", i)
+		err := d.populateValue(newV.Interface(), v)
+		if err != nil && !d.mismatch(err) {
+			return 0, err
+		}
+		d.path = prev
+		if dt.Kind() == reflect.Ptr {
+			dvi.Set(reflect.Append(dvi, newV))
+		} else {
+			dvi.Set(reflect.Append(dvi, newV.Elem()))
 		}
 	}
-	return fields
+	if _, err := dec.Token(); err != nil {
+		return 0, err
+	}
+	return dvi.Len(), nil
 }
 
-func getFieldNameMap(v reflect.Value) map[string]string {
-	// Get the actual type we care about. Indirect any pointers, and handle
-	ind := reflect.Indirect(v)
-	t := ind.Type()
-	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
-		t = t.Elem()
-		if t.Kind() == reflect.Ptr {
-			t = t.Elem()
-		}
+// Populates the struct field f described by fc with the value src
+func (d *decoder) populateField(f reflect.Value, fc *fieldCodec, src interface{}) error {
+	if f.Kind() == reflect.Ptr && f.IsNil() {
+		f.Set(reflect.New(f.Type().Elem()))
 	}
-	if f, ok := fieldNameCache[t]; ok {
-		return f
+	if !reflect.Indirect(f).CanSet() {
+		return nil
 	}
 
-	fields := getFields(t)
-	fieldMap := make(map[string]string)
-	for _, f := range fields {
-		if name, _ := parseTag(f.Tag.Get("parse")); name != "" && name != "-" {
-			fieldMap[name] = f.Name
+	fptr := f
+	if f.Kind() != reflect.Ptr {
+		fptr = f.Addr()
+	}
+	if fc.unmarshaler {
+		if u, ok := fptr.Interface().(Unmarshaler); ok {
+			return u.UnmarshalParse(src)
 		}
 	}
-	fieldNameCache[t] = fieldMap
-	return fieldMap
+	if s, ok := src.(string); ok && fc.quoted {
+		return json.Unmarshal([]byte(s), fptr.Interface())
+	}
+	return d.populateValue(fptr.Interface(), src)
 }
 
-func populateValue(dst interface{}, src interface{}) (err error) {
+func (d *decoder) populateValue(dst interface{}, src interface{}) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(error); ok {
@@ -258,6 +597,22 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 	dv := reflect.ValueOf(dst)
 	dvi := reflect.Indirect(dv)
 
+	// Values set on an Update are applied to the local object in their
+	// original form where possible, or as Parse would return them
+	if mv, ok := src.(*marshaledValue); ok {
+		if sv := reflect.ValueOf(mv.m); sv.Type().AssignableTo(dvi.Type()) {
+			dvi.Set(sv)
+			return nil
+		}
+		if src, err = mv.decoded(); err != nil {
+			return err
+		}
+	}
+
+	if u, ok := dst.(Unmarshaler); ok {
+		return u.UnmarshalParse(src)
+	}
+
 	if src == nil {
 		dvi.Set(reflect.Zero(dvi.Type()))
 		return nil
@@ -277,10 +632,12 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 				} else {
 					newV = reflect.New(dt)
 				}
-				err := populateValue(newV.Interface(), sv.Index(i).Interface())
-				if err != nil {
+				prev := d.enterIndex(d.path, i)
+				err := d.populateValue(newV.Interface(), sv.Index(i).Interface())
+				if err != nil && !d.mismatch(err) {
 					return err
 				}
+				d.path = prev
 				if dt.Kind() == reflect.Ptr {
 					dvi = reflect.Append(dvi, newV)
 				} else {
@@ -323,42 +680,53 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 				return fmt.Errorf("parse: expected string or Date type, got %s", sv.Type())
 			}
 		} else if svi.Kind() == reflect.Map {
-			fieldNameMap := getFieldNameMap(dvi)
+			sc := codecFor(dvi.Type())
 			if m, ok := src.(map[string]interface{}); ok {
-				if f := dvi.FieldByName("Extra"); f.IsValid() && f.CanSet() && f.IsNil() {
-					f.Set(reflect.ValueOf(make(map[string]interface{})))
+				var extra reflect.Value
+				if sc.extra != nil {
+					if extra = fieldByIndex(dvi, sc.extra, true); extra.IsValid() && extra.CanSet() && extra.IsNil() {
+						extra.Set(reflect.ValueOf(make(map[string]interface{})))
+					}
+				}
+				var found map[*fieldCodec]bool
+				if d.strict {
+					found = map[*fieldCodec]bool{}
 				}
 				for k, v := range m {
 					if k == "__type" || k == "className" {
 						continue
 					}
-					if nk, ok := fieldNameMap[k]; ok {
-						k = nk
+					fc, ok := sc.lookup(k)
+					if d.strict && !ok {
+						d.unknown(k)
 					}
-					k = firstToUpper(k)
-					if f := dvi.FieldByName(k); f.IsValid() && v != nil {
-						if f.Kind() == reflect.Ptr {
-							if f.IsNil() {
-								f.Set(reflect.New(f.Type().Elem()))
-							}
+					key := k
+					if ok {
+						k = fc.name
+					} else {
+						k = firstToUpper(k)
+					}
+					if ok && v != nil {
+						f := fieldByIndex(dvi, fc.index, true)
+						if !f.IsValid() {
+							continue
 						}
-						fi := reflect.Indirect(f)
-						if fi.CanSet() {
-							var err error
-							if f.Kind() == reflect.Ptr {
-								err = populateValue(f.Interface(), v)
-							} else {
-								fptr := f.Addr()
-								err = populateValue(fptr.Interface(), v)
-							}
-							if err != nil {
-								return fmt.Errorf("parse: can not set field %s - %s", k, err)
-							}
+						if found != nil {
+							found[fc] = true
+						}
+						prev := d.enter(key)
+						err := d.populateField(f, fc, v)
+						if err != nil && !d.mismatch(err) {
+							return fmt.Errorf("parse: can not set field %s - %s", k, err)
 						}
-					} else if f := dvi.FieldByName("Extra"); f.IsValid() && f.Kind() == reflect.Map {
-						f.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+						d.path = prev
+					} else if extra.IsValid() && !extra.IsNil() {
+						extra.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
 					}
 				}
+				if found != nil && m["__type"] != "Pointer" {
+					d.checkRequired(sc, found)
+				}
 			} else {
 				return fmt.Errorf("parse: expected map[string]interface{} got %s", sv.Type())
 			}
@@ -370,7 +738,7 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			if f := newvi.FieldByName("Id"); f.CanSet() {
 				f.Set(reflect.ValueOf(p.Id))
 			}
-			return populateValue(dst, newv.Interface())
+			return d.populateValue(dst, newv.Interface())
 		} else {
 			return fmt.Errorf("parse: expected map, got %s", svi.Kind())
 		}
@@ -407,9 +775,9 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			}
 		} else if m, ok := src.(map[string]interface{}); ok {
 			if c, ok := m["className"]; ok {
-				if t, ok := registeredTypes[c.(string)]; ok {
+				if t, ok := d.types.lookup(c.(string)); ok {
 					tv := reflect.New(t)
-					if err := populateValue(tv.Interface(), src); err != nil {
+					if err := d.populateValue(tv.Interface(), src); err != nil {
 						return err
 					}
 					dvi.Set(tv)
@@ -417,7 +785,7 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 				}
 			} else if t, ok := m["__type"]; ok && t == "File" {
 				f := File{}
-				if err := populateValue(&f, m); err != nil {
+				if err := d.populateValue(&f, m); err != nil {
 					return err
 				}
 				dvi.Set(reflect.ValueOf(&f))
@@ -439,11 +807,17 @@ func populateValue(dst interface{}, src interface{}) (err error) {
 			return nil
 		} else if sv.Type().ConvertibleTo(dvi.Type()) {
 			newV := sv.Convert(dvi.Type())
+			if d.strict && !convertsExactly(sv, newV) {
+				d.mismatch(fmt.Errorf("parse: %v overflows or truncates %s", src, dvi.Type()))
+			}
 			if dvi.CanSet() {
 				dvi.Set(newV)
 			}
 			return nil
 		}
+		if d.strict {
+			d.mismatch(fmt.Errorf("parse: expected %s, got %s", dvi.Type(), sv.Type()))
+		}
 	}
 	return nil
 }
@@ -0,0 +1,56 @@
+package parse
+
+import "sync"
+
+// Enable or disable coalescing of identical concurrent read requests.
+//
+// When enabled, GET requests (e.g. Query.Get, Query.Find or GetConfig) issued
+// while an identical request is already in flight do not make a second HTTP
+// call. Instead, they wait for the in-flight request to complete and share its
+// response. Requests are considered identical if they have the same method,
+// endpoint (including any query parameters), and credentials. Each caller
+// decodes the shared response into its own destination value.
+func (c *Client) SetRequestCoalescing(enabled bool) {
+	if enabled {
+		c.flights = &flightGroup{m: map[string]*flight{}}
+	} else {
+		c.flights = nil
+	}
+}
+
+type flight struct {
+	wg  sync.WaitGroup
+	b   []byte
+	err error
+}
+
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[string]*flight
+}
+
+// Calls fn, unless a call with the same key is already in progress, in which
+// case this waits for that call to complete and returns its results
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if f, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		f.wg.Wait()
+		return f.b, f.err
+	}
+
+	f := &flight{}
+	f.wg.Add(1)
+	g.m[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+		f.wg.Done()
+	}()
+
+	f.b, f.err = fn()
+	return f.b, f.err
+}
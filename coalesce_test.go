@@ -0,0 +1,86 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprintf(w, `{"objectId":"abc","username":"kylemcc"}`)
+	})
+	defer teardownTestServer()
+
+	testClient.SetRequestCoalescing(true)
+	defer testClient.SetRequestCoalescing(false)
+
+	var wg sync.WaitGroup
+	users := make([]User, 10)
+	errs := make([]error, 10)
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q, _ := testClient.NewQuery(&users[i])
+			errs[i] = q.Get("abc")
+		}(i)
+	}
+
+	// give all goroutines a chance to join the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected identical requests to be coalesced. got [%d] requests", n)
+	}
+
+	for i, u := range users {
+		if errs[i] != nil {
+			t.Errorf("unexpected error: %v", errs[i])
+		} else if u.Id != "abc" || u.Username != "kylemcc" {
+			t.Errorf("unexpected user: %+v", u)
+		}
+	}
+}
+
+func TestRequestCoalescingDistinguishesCredentials(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	testClient.SetRequestCoalescing(true)
+	defer testClient.SetRequestCoalescing(false)
+
+	var wg sync.WaitGroup
+	for _, st := range []string{"", "token1", "token2"} {
+		wg.Add(1)
+		go func(st string) {
+			defer wg.Done()
+			q, _ := testClient.NewQuery(&User{})
+			q.SetSessionToken(st)
+			q.Get("abc")
+		}(st)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected requests with different credentials not to be coalesced. got [%d] requests", n)
+	}
+}
@@ -0,0 +1,387 @@
+package parse
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Implemented by types that control their own representation when sent to
+// Parse. MarshalParse returns a value that is encoded as JSON in place of the
+// receiver, e.g. a string, number or map[string]interface{}.
+type Marshaler interface {
+	MarshalParse() (interface{}, error)
+}
+
+// Implemented by types that control how they are populated from Parse
+// responses. UnmarshalParse is called with the decoded JSON value of the
+// field, i.e. a string, float64, bool, []interface{} or map[string]interface{}.
+type Unmarshaler interface {
+	UnmarshalParse(v interface{}) error
+}
+
+// Describes how a single struct field maps to a Parse field
+type fieldCodec struct {
+	// Name of the struct field
+	name string
+
+	// Name of the Parse field
+	wireName string
+
+	// Index sequence of the field for use with fieldByIndex
+	index []int
+
+	// Whether the field is included in create requests
+	encode bool
+
+	// Whether the field is omitted from create requests when empty
+	omitEmpty bool
+
+	// Whether the field is encoded as a JSON string (the "string" option)
+	quoted bool
+
+	// Whether the field is never sent on create or update (the "readonly" option)
+	readOnly bool
+
+	// Whether the field must be present in responses in strict decoding mode
+	// (the "required" option)
+	required bool
+
+	// Whether changes to the field are saved as increments (the "counter" option)
+	counter bool
+
+	// Whether the name of the field was set explicitly in its parse tag
+	tagged bool
+
+	// Whether the field or a pointer to it implements Marshaler or Unmarshaler
+	marshaler   bool
+	unmarshaler bool
+}
+
+// A precompiled description of how a struct type maps to a Parse class.
+// Computing this once per type avoids walking struct fields with reflection
+// each time a value is encoded or decoded.
+type structCodec struct {
+	// All fields, including those promoted from embedded and inline structs,
+	// in index order
+	fields []*fieldCodec
+
+	// Fields by Parse field name
+	byName map[string]*fieldCodec
+
+	// Fields by lower-cased Parse field name, for case-insensitive matching
+	byFold map[string]*fieldCodec
+
+	// Index sequence of the Extra field, or nil if the type has none
+	extra []int
+}
+
+var structCodecs = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structCodec
+}{m: map[reflect.Type]*structCodec{}}
+
+// Returns the codec for the struct type t, compiling and caching it if necessary
+func codecFor(t reflect.Type) *structCodec {
+	structCodecs.RLock()
+	sc, ok := structCodecs.m[t]
+	structCodecs.RUnlock()
+	if ok {
+		return sc
+	}
+
+	sc = compileCodec(t)
+	structCodecs.Lock()
+	structCodecs.m[t] = sc
+	structCodecs.Unlock()
+	return sc
+}
+
+// Compiles the codec for t. Fields are resolved following the rules of
+// encoding/json: fields of embedded structs without a name in their parse tag,
+// and of struct fields with the "inline" option, are promoted to the outer
+// struct. Where several fields share a Parse name, the shallowest wins, then
+// a field with an explicit name in its tag. Remaining conflicts are dropped.
+func compileCodec(t reflect.Type) *structCodec {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+
+	var candidates []*fieldCodec
+	depths := map[*fieldCodec]int{}
+	visited := map[reflect.Type]bool{}
+	next := []embedded{{t: t}}
+
+	for depth := 0; len(next) > 0; depth++ {
+		current := next
+		next = nil
+
+		for _, e := range current {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+
+			for i := 0; i < e.t.NumField(); i++ {
+				sf := e.t.Field(i)
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				name, opts := parseTag(sf.Tag.Get("parse"))
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr && ft.Name() == "" {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && (sf.Anonymous && name == "" || opts.Contains("inline")) {
+					if sf.Anonymous || sf.PkgPath == "" {
+						next = append(next, embedded{t: ft, index: index})
+					}
+					continue
+				}
+				if sf.PkgPath != "" {
+					continue
+				}
+
+				fc := &fieldCodec{
+					name:      sf.Name,
+					wireName:  name,
+					index:     index,
+					omitEmpty: opts.Contains("omitempty"),
+					quoted:    opts.Contains("string") && isQuotable(sf.Type),
+					readOnly:  opts.Contains("readonly"),
+					required:  opts.Contains("required"),
+					counter:   opts.Contains("counter"),
+					tagged:    name != "" && name != "-",
+				}
+				fc.marshaler = implements(sf.Type, marshalerType)
+				fc.unmarshaler = implements(sf.Type, unmarshalerType)
+				if !fc.tagged {
+					fc.wireName = firstToLower(sf.Name)
+				}
+				fc.encode = name != "-" && name != "objectId" && sf.Name != "Id" && !fc.readOnly
+				candidates = append(candidates, fc)
+				depths[fc] = depth
+			}
+		}
+	}
+
+	// Group candidates by Parse name, keeping only the dominant field of each
+	byName := map[string][]*fieldCodec{}
+	for _, fc := range candidates {
+		byName[fc.wireName] = append(byName[fc.wireName], fc)
+	}
+
+	sc := &structCodec{
+		byName: map[string]*fieldCodec{},
+		byFold: map[string]*fieldCodec{},
+	}
+	for _, fc := range candidates {
+		if dominant(byName[fc.wireName], depths) != fc {
+			continue
+		}
+		sc.fields = append(sc.fields, fc)
+	}
+	sort.Sort(byIndex(sc.fields))
+
+	for _, fc := range sc.fields {
+		sc.byName[fc.wireName] = fc
+		if _, ok := sc.byFold[strings.ToLower(fc.wireName)]; !ok {
+			sc.byFold[strings.ToLower(fc.wireName)] = fc
+		}
+		if fc.name == "Extra" && len(fc.index) > 0 {
+			if f := t.FieldByIndex(fc.index); f.Type.Kind() == reflect.Map {
+				sc.extra = fc.index
+			}
+		}
+	}
+	return sc
+}
+
+// Returns the field that takes precedence among fields sharing a Parse name,
+// or nil if there is no single such field
+func dominant(fields []*fieldCodec, depths map[*fieldCodec]int) *fieldCodec {
+	var dom *fieldCodec
+	ambiguous := false
+	for _, fc := range fields {
+		switch {
+		case dom == nil || depths[fc] < depths[dom]:
+			dom, ambiguous = fc, false
+		case depths[fc] > depths[dom]:
+		case fc.tagged && !dom.tagged:
+			dom, ambiguous = fc, false
+		case fc.tagged == dom.tagged:
+			ambiguous = true
+		}
+	}
+	if ambiguous {
+		return nil
+	}
+	return dom
+}
+
+type byIndex []*fieldCodec
+
+func (x byIndex) Len() int      { return len(x) }
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+// Returns the field for the Parse field named key. An exact match takes
+// precedence over a case-insensitive one
+func (sc *structCodec) lookup(key string) (*fieldCodec, bool) {
+	if fc, ok := sc.byName[key]; ok {
+		return fc, true
+	}
+	fc, ok := sc.byFold[strings.ToLower(key)]
+	return fc, ok
+}
+
+// Returns whether values of type t may be encoded as JSON strings with the
+// "string" option
+func isQuotable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+	return false
+}
+
+// Returns the fields of the struct pointed to by v as they are sent when
+// creating an object, by Parse field name
+func encodeFields(v interface{}) (map[string]interface{}, error) {
+	rvi := reflect.Indirect(reflect.ValueOf(v))
+	sc := codecFor(rvi.Type())
+
+	payload := map[string]interface{}{}
+	for _, f := range sc.fields {
+		if !f.encode {
+			continue
+		}
+
+		fv := fieldByIndex(rvi, f.index, false)
+		if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		ev, err := encodeField(fv, f)
+		if err != nil {
+			return nil, err
+		}
+		payload[f.wireName] = ev
+	}
+	return payload, nil
+}
+
+// Returns the representation of the struct field v described by fc for use in
+// a create request
+func encodeField(v reflect.Value, fc *fieldCodec) (interface{}, error) {
+	if fc.marshaler {
+		if m, ok := asMarshaler(v); ok {
+			mv, err := m.MarshalParse()
+			if err != nil {
+				return nil, err
+			}
+			return encodeForRequest(mv), nil
+		}
+	}
+	if canBeNil(v) && v.IsNil() {
+		return nil, nil
+	}
+	if fc.quoted {
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return encodeForRequest(v.Interface()), nil
+}
+
+// Wraps a Marshaler passed to encodeForRequest. MarshalParse is called when
+// the request is encoded, so that any error it returns fails the request.
+type marshaledValue struct {
+	m Marshaler
+}
+
+func (mv *marshaledValue) MarshalJSON() ([]byte, error) {
+	v, err := mv.m.MarshalParse()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodeForRequest(v))
+}
+
+// Returns the value as it would be decoded from a response from Parse
+func (mv *marshaledValue) decoded() (interface{}, error) {
+	b, err := mv.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// Returns whether t or a pointer to t implements the interface type it
+func implements(t, it reflect.Type) bool {
+	return t.Implements(it) || reflect.PtrTo(t).Implements(it)
+}
+
+// Returns v, or a pointer to v if it is addressable, as a Marshaler
+func asMarshaler(v reflect.Value) (Marshaler, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if m, ok := v.Interface().(Marshaler); ok {
+		return m, true
+	}
+	if v.CanAddr() {
+		m, ok := v.Addr().Interface().(Marshaler)
+		return m, ok
+	}
+	return nil, false
+}
+
+// Returns the nested field of v identified by index. Nil embedded pointers
+// along the way are allocated if alloc is true and v is settable. Otherwise,
+// the zero Value is returned if a nil pointer is encountered.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
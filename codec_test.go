@@ -0,0 +1,403 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type CodecTestEmbedded struct {
+	Nickname string `parse:"nick"`
+}
+
+type codecTestType struct {
+	Base
+	*CodecTestEmbedded
+	Name      string
+	Count     int    `parse:"num,omitempty"`
+	Ignored   string `parse:"-"`
+	Tags      []string
+	Location  GeoPoint
+	Timestamp time.Time
+}
+
+func TestStructCodec(t *testing.T) {
+	sc := codecFor(reflect.TypeOf(codecTestType{}))
+
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"objectId", "Id"},
+		{"createdAt", "CreatedAt"},
+		{"ACL", "ACL"},
+		{"name", "Name"},
+		{"num", "Count"},
+		{"nick", "Nickname"},
+		{"tags", "Tags"},
+	}
+	for _, tc := range cases {
+		if fc, ok := sc.lookup(tc.key); !ok {
+			t.Errorf("expected key [%s] to map to a field", tc.key)
+		} else if fc.name != tc.expected {
+			t.Errorf("wrong field for key [%s]. got [%s] expected [%s]", tc.key, fc.name, tc.expected)
+		}
+	}
+
+	if _, ok := sc.lookup("unknown"); ok {
+		t.Errorf("expected key [unknown] not to map to a field")
+	}
+	if sc.extra == nil {
+		t.Errorf("expected Extra field to be found")
+	}
+}
+
+func TestCreateBodySkipsNilEmbeddedPointer(t *testing.T) {
+	cr := &createRequest{v: &codecTestType{Name: "foo"}}
+	b, err := cr.body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := map[string]interface{}{}
+	json.Unmarshal([]byte(b), &actual)
+	if _, ok := actual["nick"]; ok {
+		t.Errorf("expected field on nil embedded pointer to be omitted. got %s", b)
+	}
+	if actual["name"] != "foo" {
+		t.Errorf("expected name to be set. got %s", b)
+	}
+	if _, ok := actual["num"]; ok {
+		t.Errorf("expected empty omitempty field to be omitted. got %s", b)
+	}
+}
+
+func TestPopulateAllocatesEmbeddedPointer(t *testing.T) {
+	v := codecTestType{}
+	if err := testClient.populateValue(&v, map[string]interface{}{"nick": "kyle", "name": "Kyle", "other": 1.0}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.CodecTestEmbedded == nil || v.Nickname != "kyle" || v.Name != "Kyle" {
+		t.Errorf("unexpected value: %+v", v)
+	}
+	if v.Extra["Other"] != 1.0 {
+		t.Errorf("expected unknown field to be stored in Extra. got: %v", v.Extra)
+	}
+}
+
+type codecTestAddress struct {
+	Street string
+	City   string `parse:"town"`
+}
+
+type codecTestMoney int64
+
+func (m codecTestMoney) MarshalParse() (interface{}, error) {
+	return fmt.Sprintf("%d.%02d", m/100, m%100), nil
+}
+
+func (m *codecTestMoney) UnmarshalParse(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("expected string, got %T", v)
+	}
+	var d, c int64
+	if _, err := fmt.Sscanf(s, "%d.%02d", &d, &c); err != nil {
+		return err
+	}
+	*m = codecTestMoney(d*100 + c)
+	return nil
+}
+
+type codecTestTags struct {
+	Base
+	URL      string
+	UserID   string
+	Score    int              `parse:"score,string,omitempty"`
+	Verified *bool            `parse:",string"`
+	Secret   string           `parse:"secret,readonly"`
+	Address  codecTestAddress `parse:",inline"`
+	Price    codecTestMoney
+	Total    *codecTestMoney
+}
+
+func TestParseTag(t *testing.T) {
+	name, opts := parseTag("score,string,omitempty")
+	if name != "score" {
+		t.Errorf("wrong name. got [%s] expected [score]", name)
+	}
+	for _, o := range []string{"string", "omitempty"} {
+		if !opts.Contains(o) {
+			t.Errorf("expected options to contain [%s]", o)
+		}
+	}
+	if opts.Contains("readonly") || opts.Contains("omit") {
+		t.Errorf("unexpected option in [%s]", opts)
+	}
+}
+
+func TestStructCodecTagSemantics(t *testing.T) {
+	sc := codecFor(reflect.TypeOf(codecTestTags{}))
+
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"url", "URL"},
+		{"userId", "UserID"},
+		{"userID", "UserID"},
+		{"SCORE", "Score"},
+		{"street", "Street"},
+		{"town", "City"},
+	}
+	for _, tc := range cases {
+		if fc, ok := sc.lookup(tc.key); !ok {
+			t.Errorf("expected key [%s] to map to a field", tc.key)
+		} else if fc.name != tc.expected {
+			t.Errorf("wrong field for key [%s]. got [%s] expected [%s]", tc.key, fc.name, tc.expected)
+		}
+	}
+	if _, ok := sc.lookup("address"); ok {
+		t.Errorf("expected inline field not to be mapped by its own name")
+	}
+}
+
+func TestStructCodecDominance(t *testing.T) {
+	type inner struct {
+		Name  string
+		Other string
+		Title string
+	}
+	type outer struct {
+		inner
+		Name  string
+		Alias string `parse:"other"`
+	}
+	type conflict struct {
+		codecTestAddress
+		CodecTestEmbedded
+		Street string `parse:"nick"`
+	}
+
+	sc := codecFor(reflect.TypeOf(outer{}))
+	if fc, _ := sc.lookup("name"); fc == nil || len(fc.index) != 1 {
+		t.Errorf("expected shallower field to take precedence")
+	}
+	if fc, _ := sc.lookup("other"); fc == nil || fc.name != "Alias" {
+		t.Errorf("expected tagged field to take precedence")
+	}
+	if fc, _ := sc.lookup("title"); fc == nil || len(fc.index) != 2 {
+		t.Errorf("expected embedded field to be promoted")
+	}
+
+	sc = codecFor(reflect.TypeOf(conflict{}))
+	if fc, _ := sc.lookup("nick"); fc == nil || len(fc.index) != 1 {
+		t.Errorf("expected outer tagged field to take precedence")
+	}
+}
+
+func TestCreateBodyTagSemantics(t *testing.T) {
+	verified := true
+	total := codecTestMoney(1999)
+	cr := &createRequest{v: &codecTestTags{
+		URL:      "http://example.com",
+		Score:    42,
+		Verified: &verified,
+		Secret:   "s3cr3t",
+		Address:  codecTestAddress{Street: "Main St", City: "Chicago"},
+		Price:    1250,
+		Total:    &total,
+	}}
+	b, err := cr.body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := map[string]interface{}{}
+	json.Unmarshal([]byte(b), &actual)
+	expected := map[string]interface{}{
+		"uRL":      "http://example.com",
+		"userID":   "",
+		"score":    "42",
+		"verified": "true",
+		"street":   "Main St",
+		"town":     "Chicago",
+		"price":    "12.50",
+		"total":    "19.99",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected body.\ngot:      %v\nexpected: %v", actual, expected)
+	}
+
+	cr = &createRequest{v: &codecTestTags{}}
+	b, _ = cr.body()
+	actual = map[string]interface{}{}
+	json.Unmarshal([]byte(b), &actual)
+	if _, ok := actual["score"]; ok {
+		t.Errorf("expected empty omitempty field to be omitted. got %s", b)
+	}
+	if v, ok := actual["total"]; !ok || v != nil {
+		t.Errorf("expected nil Marshaler to be encoded as null. got %s", b)
+	}
+}
+
+func TestPopulateTagSemantics(t *testing.T) {
+	v := codecTestTags{}
+	err := testClient.populateValue(&v, map[string]interface{}{
+		"url":      "http://example.com",
+		"userId":   "abc",
+		"score":    "42",
+		"verified": "true",
+		"secret":   "s3cr3t",
+		"street":   "Main St",
+		"town":     "Chicago",
+		"price":    "12.50",
+		"total":    "19.99",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if v.URL != "http://example.com" || v.UserID != "abc" || v.Score != 42 || v.Secret != "s3cr3t" {
+		t.Errorf("unexpected value: %+v", v)
+	}
+	if v.Verified == nil || !*v.Verified {
+		t.Errorf("expected quoted bool to be decoded. got: %v", v.Verified)
+	}
+	if v.Address.Street != "Main St" || v.Address.City != "Chicago" {
+		t.Errorf("expected inline fields to be populated. got: %+v", v.Address)
+	}
+	if v.Price != 1250 || v.Total == nil || *v.Total != 1999 {
+		t.Errorf("expected Unmarshaler to be used. got: %v %v", v.Price, v.Total)
+	}
+
+	if err := testClient.populateValue(&v, map[string]interface{}{"price": 12.5}); err == nil {
+		t.Errorf("expected Unmarshaler error to be returned")
+	}
+}
+
+func TestUpdateSkipsReadOnlyFields(t *testing.T) {
+	v := codecTestTags{Secret: "s3cr3t"}
+	v.Id = "abc"
+	u, _ := testClient.NewUpdate(&v)
+	u.Set("secret", "other")
+	u.Set("url", "http://example.com")
+
+	b, err := u.body()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b != `{"url":"http://example.com"}` {
+		t.Errorf("unexpected body. got %s", b)
+	}
+}
+
+type codecTestBadValue struct{}
+
+func (codecTestBadValue) MarshalParse() (interface{}, error) {
+	return nil, errors.New("bad value")
+}
+
+func TestMarshalerInRequests(t *testing.T) {
+	v := codecTestTags{}
+	v.Id = "abc"
+	u, _ := testClient.NewUpdate(&v)
+	u.Set("price", codecTestMoney(1250))
+	if b, err := u.body(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if b != `{"price":"12.50"}` {
+		t.Errorf("unexpected update body. got %s", b)
+	}
+
+	u.Set("price", codecTestBadValue{})
+	if _, err := u.body(); err == nil {
+		t.Errorf("expected MarshalParse error to be returned")
+	}
+
+	q, _ := testClient.NewQuery(&[]codecTestTags{})
+	q.EqualTo("price", codecTestMoney(1250))
+	q.GreaterThan("total", codecTestMoney(1))
+	q.In("tags", codecTestMoney(5), codecTestMoney(105))
+	w, _ := json.Marshal(q.(*query).where)
+	expected := `{"price":"12.50","tags":{"$in":["0.05","1.05"]},"total":{"$gt":"0.01"}}`
+	if string(w) != expected {
+		t.Errorf("unexpected where clause.\ngot:      %s\nexpected: %s", w, expected)
+	}
+}
+
+func TestUnmarshalerInResponses(t *testing.T) {
+	var prices []codecTestMoney
+	if err := testClient.handleResponse([]byte(`{"results":["1.00","2.50"]}`), &prices); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(prices, []codecTestMoney{100, 250}) {
+		t.Errorf("unexpected values: %v", prices)
+	}
+
+	var price codecTestMoney
+	if err := testClient.populateValue(&price, &marshaledValue{m: codecTestMoney(99)}); err != nil || price != 99 {
+		t.Errorf("expected marshaled value to be assigned. got %v (%v)", price, err)
+	}
+	var s string
+	if err := testClient.populateValue(&s, &marshaledValue{m: codecTestMoney(99)}); err != nil || s != "0.99" {
+		t.Errorf("expected marshaled value to be decoded. got %v (%v)", s, err)
+	}
+}
+
+func benchmarkResults(n int) []interface{} {
+	results := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		results = append(results, map[string]interface{}{
+			"objectId":  fmt.Sprintf("id%d", i),
+			"createdAt": "2014-12-20T18:31:19.123Z",
+			"updatedAt": "2014-12-20T18:31:19.123Z",
+			"name":      "name",
+			"num":       float64(i),
+			"tags":      []interface{}{"a", "b", "c"},
+			"location":  map[string]interface{}{"__type": "GeoPoint", "latitude": 41.9, "longitude": -87.6},
+			"timestamp": map[string]interface{}{"__type": "Date", "iso": "2014-12-20T18:31:19.123Z"},
+		})
+	}
+	return results
+}
+
+func BenchmarkPopulateValue(b *testing.B) {
+	results := benchmarkResults(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst := []codecTestType{}
+		if err := testClient.populateValue(&dst, results); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateBody(b *testing.B) {
+	cr := &createRequest{v: &codecTestType{
+		CodecTestEmbedded: &CodecTestEmbedded{Nickname: "nick"},
+		Name:              "name",
+		Count:             11,
+		Tags:              []string{"a", "b", "c"},
+		Timestamp:         time.Now(),
+	}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cr.body(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHandleResponse(b *testing.B) {
+	body, _ := json.Marshal(map[string]interface{}{"results": benchmarkResults(1000)})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst := []codecTestType{}
+		if err := testClient.handleResponse(body, &dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -30,7 +30,7 @@ func setupTestServer(handler http.HandlerFunc) *httptest.Server {
 	ctx.oldHost = testClient.host
 	ctx.oldHttpClient = testClient.httpClient
 
-	testClient.host = _url.Host
+	testClient.host = "https://" + _url.Host
 	testClient.httpClient = &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
@@ -0,0 +1,115 @@
+package parse
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits the number of requests to Parse that may be in flight at once.
+//
+// In addition to an overall maximum, separate limits may be set for individual
+// classes and operations. E.g., to allow at most 10 concurrent requests, of
+// which at most 2 may be Count queries, and at most 4 may involve the _User
+// class:
+//
+//	l := parse.NewConcurrencyLimiter(10)
+//	l.SetOperationLimit("count", 2)
+//	l.SetClassLimit("_User", 4)
+//	cli.SetConcurrencyLimiter(l)
+//
+// Requests exceeding any applicable limit block until a slot is available.
+// A ConcurrencyLimiter is safe for concurrent use, and may be shared between
+// clients.
+type ConcurrencyLimiter struct {
+	all chan struct{}
+
+	mu         sync.Mutex
+	classes    map[string]chan struct{}
+	operations map[string]chan struct{}
+}
+
+// Create a new ConcurrencyLimiter allowing at most max requests in flight. A
+// max of 0 places no overall limit on requests, which is useful in combination
+// with per-class or per-operation limits.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{
+		classes:    map[string]chan struct{}{},
+		operations: map[string]chan struct{}{},
+	}
+	if max > 0 {
+		l.all = make(chan struct{}, max)
+	}
+	return l
+}
+
+// Allow at most max concurrent requests for the class named className. A max
+// of 0 removes the limit. Changing a limit does not affect requests that are
+// already in flight.
+func (l *ConcurrencyLimiter) SetClassLimit(className string, max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	setSemaphore(l.classes, className, max)
+}
+
+// Allow at most max concurrent requests for the operation named op (see
+// Operation for the list of names). A max of 0 removes the limit. Changing a
+// limit does not affect requests that are already in flight.
+func (l *ConcurrencyLimiter) SetOperationLimit(op string, max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	setSemaphore(l.operations, op, max)
+}
+
+func setSemaphore(m map[string]chan struct{}, key string, max int) {
+	if max > 0 {
+		m[key] = make(chan struct{}, max)
+	} else {
+		delete(m, key)
+	}
+}
+
+func (l *ConcurrencyLimiter) wait(ctx context.Context, o *Operation) (func(int, []byte), time.Duration, error) {
+	l.mu.Lock()
+	sems := make([]chan struct{}, 0, 3)
+	// Acquire the most specific limits first, so that requests waiting on
+	// a class or operation limit don't hold slots that other requests could use
+	if s, ok := l.operations[o.Name]; ok {
+		sems = append(sems, s)
+	}
+	if s, ok := l.classes[o.ClassName]; ok && o.ClassName != "" {
+		sems = append(sems, s)
+	}
+	if l.all != nil {
+		sems = append(sems, l.all)
+	}
+	l.mu.Unlock()
+
+	release := func(held []chan struct{}) {
+		for _, s := range held {
+			<-s
+		}
+	}
+
+	start := time.Now()
+	for i, s := range sems {
+		select {
+		case s <- struct{}{}:
+		case <-ctx.Done():
+			release(sems[:i])
+			return nil, time.Since(start), ctx.Err()
+		}
+	}
+
+	return func(int, []byte) { release(sems) }, time.Since(start), nil
+}
+
+// Set the ConcurrencyLimiter used to restrict the number of concurrent
+// requests made by this client. Pass nil to remove the limit.
+func (c *Client) SetConcurrencyLimiter(l *ConcurrencyLimiter) {
+	if l == nil {
+		c.concurrency = nil
+	} else {
+		c.concurrency = l
+	}
+}
@@ -0,0 +1,77 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	var inFlight, maxInFlight, countInFlight, maxCountInFlight int32
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		isCount := r.URL.Query().Get("count") == "1"
+
+		n := atomic.AddInt32(&inFlight, 1)
+		var cn int32
+		if isCount {
+			cn = atomic.AddInt32(&countInFlight, 1)
+		}
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		for isCount {
+			m := atomic.LoadInt32(&maxCountInFlight)
+			if cn <= m || atomic.CompareAndSwapInt32(&maxCountInFlight, m, cn) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		if isCount {
+			atomic.AddInt32(&countInFlight, -1)
+			fmt.Fprintf(w, `{"count":1,"results":[]}`)
+		} else {
+			fmt.Fprintf(w, `{"objectId":"abc"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	l := NewConcurrencyLimiter(3)
+	l.SetOperationLimit("count", 1)
+	cli := *testClient
+	cli.SetConcurrencyLimiter(l)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			q, _ := cli.NewQuery(&User{})
+			if err := q.Get("abc"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			q, _ := cli.NewQuery(&User{})
+			if _, err := q.Count(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m := atomic.LoadInt32(&maxInFlight); m > 3 {
+		t.Errorf("expected at most 3 concurrent requests. got [%d]", m)
+	}
+	if m := atomic.LoadInt32(&maxCountInFlight); m != 1 {
+		t.Errorf("expected at most 1 concurrent count request. got [%d]", m)
+	}
+}
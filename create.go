@@ -38,7 +38,8 @@ func (c *Client) Signup(username string, password string, user interface{}) erro
 	if b, err := c.doRequest(cr); err != nil {
 		return err
 	} else {
-		return handleResponse(b, user)
+		c.invalidateCache(user)
+		return c.track(user, c.handleResponse(b, user))
 	}
 }
 
@@ -56,7 +57,8 @@ func (c *Client) create(v interface{}, useMasterKey bool, sessionToken string) e
 	if b, err := c.doRequest(cr); err != nil {
 		return err
 	} else {
-		return handleResponse(b, v)
+		c.invalidateCache(v)
+		return c.track(v, c.handleResponse(b, v))
 	}
 }
 
@@ -69,43 +71,16 @@ func (c *createRequest) endpoint() (string, error) {
 }
 
 func (c *createRequest) body() (string, error) {
-	payload := map[string]interface{}{}
+	payload, err := encodeFields(c.v)
+	if err != nil {
+		return "", err
+	}
 
 	if c.isUser {
 		payload["username"] = c.username
 		payload["password"] = c.password
 	}
 
-	rv := reflect.ValueOf(c.v)
-	rvi := reflect.Indirect(rv)
-	rt := rvi.Type()
-	fields := getFields(rt)
-
-	for _, f := range fields {
-		var name string
-		var fv reflect.Value
-
-		if n, o := parseTag(f.Tag.Get("parse")); n == "-" || n == "objectId" || f.Name == "Id" || f.Type == reflect.TypeOf(Base{}) {
-			continue
-		} else if fv = rvi.FieldByName(f.Name); !fv.IsValid() || o == "omitempty" && isEmptyValue(fv) {
-			continue
-		} else {
-			name = n
-		}
-
-		var fname string
-		if name != "" {
-			fname = name
-		} else {
-			fname = firstToLower(f.Name)
-		}
-		if canBeNil(fv) && fv.IsNil() {
-			payload[fname] = nil
-		} else {
-			payload[fname] = encodeForRequest(fv.Interface())
-		}
-	}
-
 	b, err := json.Marshal(payload)
 	if err != nil {
 		return "", err
@@ -23,6 +23,9 @@ func (c *Client) _delete(v interface{}, useMasterKey bool, sessionToken string)
 		shouldUseMasterKey: useMasterKey,
 		st:                 sessionToken,
 	})
+	if err == nil {
+		c.invalidateCache(v)
+	}
 	return err
 }
 
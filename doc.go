@@ -1,2 +1,20 @@
 // Package parse provides a full-featured client for the Parse (http://parse.com) PAAS REST API
+//
+// Struct fields are mapped to Parse fields following the same rules as
+// encoding/json, using the "parse" struct tag. By default, a field maps to the
+// Parse field of the same name with the first letter lower-cased, and fields in
+// responses are matched case-insensitively. The tag may specify a name followed
+// by any of these options:
+//
+//	omitempty  omit the field from create requests if it has an empty value
+//	string     encode a number, bool or string field as a JSON string
+//	readonly   never send the field in create or update requests
+//	inline     promote the fields of a struct field to the outer struct
+//	required   report the field as missing if absent in strict decoding mode
+//	counter    save changes to a numeric field as atomic increments (see
+//	           Client.NewTrackedUpdate)
+//
+// Types implementing Marshaler and Unmarshaler control their own
+// representation in Parse. They are used wherever values are sent or received,
+// including create and update requests, query constraints and responses.
 package parse
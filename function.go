@@ -45,7 +45,7 @@ func (c *Client) callFn(name string, params Params, resp interface{}, sessionTok
 		if err := json.Unmarshal(b, &r); err != nil {
 			return err
 		}
-		return populateValue(resp, r.Result)
+		return c.populateValue(resp, r.Result)
 	}
 }
 
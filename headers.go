@@ -0,0 +1,127 @@
+package parse
+
+import "net/http"
+
+const (
+	ClientKeyHeader      = "X-Parse-Client-Key"
+	JavascriptKeyHeader  = "X-Parse-Javascript-Key"
+	MaintenanceKeyHeader = "X-Parse-Maintenance-Key"
+	ClientVersionHeader  = "X-Parse-Client-Version"
+)
+
+// Optional headers sent with requests to Parse, e.g. to identify the
+// installation making the request, or to authenticate with a key other than
+// the REST API key. Empty fields are not sent.
+//
+// Headers may be set for all requests made by a client with
+// Client.SetHeaders, and for individual requests with Query.SetHeaders,
+// Update.SetHeaders, Client.WithHeaders or Session.SetHeaders. Non-empty
+// fields set for a request override those set for the client.
+type RequestHeaders struct {
+	// Sent in X-Parse-Installation-Id. Sessions created by logging in or
+	// signing up are bound to the installation.
+	InstallationId string
+
+	// Sent in X-Parse-Client-Key
+	ClientKey string
+
+	// Sent in X-Parse-Javascript-Key
+	JavascriptKey string
+
+	// Sent in X-Parse-Maintenance-Key, for maintenance operations on Parse
+	// Server
+	MaintenanceKey string
+
+	// Sent in X-Parse-Client-Version, e.g. "go1.2.3"
+	ClientVersion string
+}
+
+// Returns h with any non-empty fields of o replacing those of h
+func (h RequestHeaders) merge(o RequestHeaders) RequestHeaders {
+	if o.InstallationId != "" {
+		h.InstallationId = o.InstallationId
+	}
+	if o.ClientKey != "" {
+		h.ClientKey = o.ClientKey
+	}
+	if o.JavascriptKey != "" {
+		h.JavascriptKey = o.JavascriptKey
+	}
+	if o.MaintenanceKey != "" {
+		h.MaintenanceKey = o.MaintenanceKey
+	}
+	if o.ClientVersion != "" {
+		h.ClientVersion = o.ClientVersion
+	}
+	return h
+}
+
+// Returns the name and value of each header in h, including empty ones
+func (h RequestHeaders) pairs() [5][2]string {
+	return [...][2]string{
+		{InstallationIdHeader, h.InstallationId},
+		{ClientKeyHeader, h.ClientKey},
+		{JavascriptKeyHeader, h.JavascriptKey},
+		{MaintenanceKeyHeader, h.MaintenanceKey},
+		{ClientVersionHeader, h.ClientVersion},
+	}
+}
+
+// Adds the non-empty headers in h to hdr
+func (h RequestHeaders) write(hdr http.Header) {
+	for _, kv := range h.pairs() {
+		if kv[1] != "" {
+			hdr.Add(kv[0], kv[1])
+		}
+	}
+}
+
+// Implemented by requests that may set their own RequestHeaders
+type hasHeaders interface {
+	headers() RequestHeaders
+}
+
+// Returns the headers to send with op
+func (c *Client) headersFor(op request) RequestHeaders {
+	if hh, ok := op.(hasHeaders); ok {
+		return c.headers.merge(hh.headers())
+	}
+	return c.headers
+}
+
+// Set the optional headers sent with every request made by this client,
+// replacing any set previously, including by SetInstallationId
+func (c *Client) SetHeaders(h RequestHeaders) {
+	c.headers = h
+}
+
+// Set the installation Id sent with every request in the
+// X-Parse-Installation-Id header. Sessions created by logging in or signing up
+// are bound to the installation, so that they appear in its SessionObject's
+// InstallationId field.
+func (c *Client) SetInstallationId(id string) {
+	c.headers.InstallationId = id
+}
+
+// Returns a copy of this client which sends the headers h, in addition to
+// those set for this client, with every request. This may be used to set
+// headers for calls that take no per-request options, e.g.:
+//
+//	cli.WithHeaders(parse.RequestHeaders{MaintenanceKey: key}).Delete(&obj, false)
+//
+// The copy shares this client's cache, rate limiter and other settings.
+// Calling SetRateLimit on either client replaces that client's limiter
+// without stopping the one they share.
+func (c *Client) WithHeaders(h RequestHeaders) *Client {
+	if r, ok := c.limiter.(*RateLimiter); ok && c.ownsLimiter {
+		r.mu.Lock()
+		r.shared = true
+		r.mu.Unlock()
+	}
+
+	nc := *c
+	nc.headers = c.headers.merge(h)
+	nc.middleware = append([]Middleware(nil), c.middleware...)
+	nc.ownsLimiter = false
+	return &nc
+}
@@ -0,0 +1,186 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	var header http.Header
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `{"results":[{"objectId":"abc"}]}`)
+		case "POST":
+			fmt.Fprintf(w, `{"objectId":"abc","createdAt":"2014-04-01T14:44:14.123Z"}`)
+		default:
+			fmt.Fprintf(w, `{}`)
+		}
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetHeaders(RequestHeaders{
+		InstallationId: "inst1",
+		ClientKey:      "client_key",
+		ClientVersion:  "go1.0.0",
+	})
+
+	checkHeaders := func(op string, expected map[string]string) {
+		for k, v := range expected {
+			if h := header.Get(k); h != v {
+				t.Errorf("%s: wrong %s header. got [%v] expected [%v]", op, k, h, v)
+			}
+		}
+	}
+
+	q, _ := cli.NewQuery(&User{})
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error on query: %v", err)
+	}
+	checkHeaders("client", map[string]string{
+		InstallationIdHeader: "inst1",
+		ClientKeyHeader:      "client_key",
+		ClientVersionHeader:  "go1.0.0",
+		JavascriptKeyHeader:  "",
+		MaintenanceKeyHeader: "",
+	})
+
+	q.SetHeaders(RequestHeaders{InstallationId: "inst2", MaintenanceKey: "maintenance_key"})
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error on query: %v", err)
+	}
+	checkHeaders("query", map[string]string{
+		InstallationIdHeader: "inst2",
+		ClientKeyHeader:      "client_key",
+		MaintenanceKeyHeader: "maintenance_key",
+	})
+
+	u, _ := cli.NewUpdate(&User{Base: Base{Id: "abc"}})
+	u.Set("city", "Chicago")
+	u.SetHeaders(RequestHeaders{JavascriptKey: "js_key"})
+	if err := u.Execute(); err != nil {
+		t.Errorf("unexpected error on update: %v", err)
+	}
+	checkHeaders("update", map[string]string{
+		InstallationIdHeader: "inst1",
+		JavascriptKeyHeader:  "js_key",
+	})
+
+	wc := cli.WithHeaders(RequestHeaders{MaintenanceKey: "maintenance_key"})
+	if err := wc.Delete(&User{Base: Base{Id: "abc"}}, false); err != nil {
+		t.Errorf("unexpected error on delete: %v", err)
+	}
+	checkHeaders("delete", map[string]string{
+		InstallationIdHeader: "inst1",
+		MaintenanceKeyHeader: "maintenance_key",
+	})
+
+	if err := cli.Create(&User{}, false); err != nil {
+		t.Errorf("unexpected error on create: %v", err)
+	}
+	checkHeaders("create", map[string]string{MaintenanceKeyHeader: ""})
+
+	var s Session = &session{client: &cli, user: &User{}, sessionToken: "session_token"}
+	s.SetHeaders(RequestHeaders{InstallationId: "inst3"})
+	if err := s.Create(&User{}); err != nil {
+		t.Errorf("unexpected error on session create: %v", err)
+	}
+	checkHeaders("session", map[string]string{
+		InstallationIdHeader: "inst3",
+		ClientKeyHeader:      "client_key",
+		SessionTokenHeader:   "session_token",
+	})
+
+	// Session headers replace those set previously for the session
+	s.SetHeaders(RequestHeaders{ClientVersion: "go2.0.0"})
+	if err := s.Create(&User{}); err != nil {
+		t.Errorf("unexpected error on session create: %v", err)
+	}
+	checkHeaders("session replaced", map[string]string{
+		InstallationIdHeader: "inst1",
+		ClientVersionHeader:  "go2.0.0",
+	})
+}
+
+func TestSessionSetHeadersConcurrent(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"objectId":"abc","createdAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "session_token"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			s.SetHeaders(RequestHeaders{InstallationId: fmt.Sprintf("inst%d", i)})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := s.Create(&User{}); err != nil {
+			t.Errorf("unexpected error on session create: %v", err)
+		}
+	}
+	<-done
+}
+
+func TestHeadersCacheIdentity(t *testing.T) {
+	cli := *testClient
+	q, _ := cli.NewQuery(&User{})
+	seen := map[string]RequestHeaders{cli.authIdentity(q): {}}
+
+	for _, h := range []RequestHeaders{
+		{InstallationId: "inst1"},
+		{InstallationId: "inst2"},
+		{ClientKey: "client_key"},
+		{JavascriptKey: "js_key"},
+		{MaintenanceKey: "maintenance_key"},
+		{ClientVersion: "go1.0.0"},
+	} {
+		q.SetHeaders(h)
+		id := cli.authIdentity(q)
+		if prev, ok := seen[id]; ok {
+			t.Errorf("requests with headers %+v share an identity with requests with headers %+v", h, prev)
+		}
+		seen[id] = h
+	}
+}
+
+func TestWithHeadersDoesNotOwnLimiter(t *testing.T) {
+	cli := *testClient
+	cli.SetRateLimit(10, 10)
+	r := cli.limiter.(*RateLimiter)
+	defer r.Stop()
+
+	wc := cli.WithHeaders(RequestHeaders{InstallationId: "inst1"})
+	wc.SetRateLimit(0, 0)
+
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+	if stopped {
+		t.Errorf("expected the copy not to stop the limiter it shares")
+	}
+	if cli.limiter != r {
+		t.Errorf("expected the original client to keep its limiter")
+	}
+
+	// Replacing the original's limit must not stop the limiter the copy
+	// still uses
+	cp := cli.WithHeaders(RequestHeaders{InstallationId: "inst2"})
+	cli.SetRateLimit(5, 5)
+	defer cli.limiter.(*RateLimiter).Stop()
+
+	r.mu.Lock()
+	stopped = r.stopped
+	r.mu.Unlock()
+	if cp.limiter != r || stopped {
+		t.Errorf("expected the copy to keep a running limiter after the original's limit was replaced")
+	}
+	if rate := r.Rate(); rate != 10 {
+		t.Errorf("expected the copy to keep its rate limit. got [%v]", rate)
+	}
+}
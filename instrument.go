@@ -0,0 +1,128 @@
+package parse
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// A Span records a single traced request. See Tracer.
+type Span interface {
+	// Set an attribute on the span
+	SetAttribute(key string, value interface{})
+
+	// Write the span's trace context to the outgoing request headers so
+	// that it is propagated to the server
+	Inject(h http.Header)
+
+	// End the span. err is the error returned for the request, if any
+	End(err error)
+}
+
+// An interface for tracing requests. This is intended to be implemented by
+// a thin adapter around a tracing library such as OpenTelemetry.
+//
+// Start is called once per request sent to Parse, with a span name of the
+// form "parse.{operation}" (e.g. "parse.find"). The following attributes are
+// set on the returned span:
+//
+// parse.class_name - the class being operated on, if any
+//
+// parse.operation - the name of the operation (see Operation)
+//
+// http.request.method - the HTTP method
+//
+// http.response.status_code - the HTTP status code, if a response was received
+//
+// parse.error_code - the Parse error code, if the request failed with an APIError
+type Tracer interface {
+	Start(spanName string) Span
+}
+
+// An interface for recording request metrics. This is intended to be
+// implemented by a thin adapter around a metrics library such as
+// OpenTelemetry.
+type Metrics interface {
+	// Called once per request with the HTTP status code, the duration of the
+	// request (including any time spent waiting on the rate limiter), and the
+	// size of the response body in bytes. status is 0 if no response was received.
+	ObserveRequest(o *Operation, status int, d time.Duration, size int)
+
+	// Called when a request is delayed by the rate limiter, with the amount
+	// of time spent waiting. Requests sent without waiting are not reported.
+	ObserveRateLimitWait(o *Operation, d time.Duration)
+
+	// Called each time an operation is retried, with the name of the
+	// operation (see Operation) and the reason for the retry. The retried
+	// request is also reported to ObserveRequest. The only reason currently
+	// reported is "invalid_session", for requests retried after
+	// re-authenticating (see Session.OnInvalidSession).
+	ObserveRetry(name, reason string)
+}
+
+// Reason reported to Metrics.ObserveRetry for requests retried after
+// re-authenticating
+const retryInvalidSession = "invalid_session"
+
+func (c *Client) observeRetry(name, reason string) {
+	if c.metrics != nil {
+		c.metrics.ObserveRetry(name, reason)
+	}
+}
+
+// Set the Tracer and Metrics used to instrument requests made by this client.
+// Either may be nil.
+//
+// Instrumentation observes requests as they are sent to Parse, after all
+// Middleware has been applied. Responses returned by Middleware without
+// calling the next Handler are not instrumented.
+func (c *Client) SetInstrumentation(t Tracer, m Metrics) {
+	c.tracer = t
+	c.metrics = m
+}
+
+// Wraps next with tracing and metrics collection
+func (c *Client) instrument(next Handler) Handler {
+	return func(o *Operation) (*Response, error) {
+		var span Span
+		if c.tracer != nil {
+			span = c.tracer.Start("parse." + o.Name)
+			span.SetAttribute("parse.operation", o.Name)
+			span.SetAttribute("http.request.method", o.Method)
+			if o.ClassName != "" {
+				span.SetAttribute("parse.class_name", o.ClassName)
+			}
+			span.Inject(o.Header)
+		}
+
+		start := time.Now()
+		resp, err := next(o)
+		d := time.Since(start)
+
+		var status, size int
+		if resp != nil {
+			status = resp.StatusCode
+			size = len(resp.Body)
+		}
+
+		if c.metrics != nil {
+			c.metrics.ObserveRequest(o, status, d, size)
+		}
+
+		if span != nil {
+			spanErr := err
+			if resp != nil {
+				span.SetAttribute("http.response.status_code", status)
+				if !(status >= 200 && status < 300) {
+					apiErr := apiError{}
+					if e := json.Unmarshal(resp.Body, &apiErr); e == nil {
+						span.SetAttribute("parse.error_code", apiErr.ErrorCode)
+						spanErr = &apiErr
+					}
+				}
+			}
+			span.End(spanErr)
+		}
+		return resp, err
+	}
+}
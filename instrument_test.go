@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *testSpan) Inject(h http.Header) {
+	h.Set("Traceparent", "00-trace-span-01")
+}
+
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(name string) Span {
+	s := &testSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+type testMetrics struct {
+	requests int
+	status   int
+	size     int
+	retries  []string
+	waits    []time.Duration
+}
+
+func (m *testMetrics) ObserveRequest(o *Operation, status int, d time.Duration, size int) {
+	m.requests++
+	m.status = status
+	m.size = size
+}
+
+func (m *testMetrics) ObserveRateLimitWait(o *Operation, d time.Duration) {
+	m.waits = append(m.waits, d)
+}
+
+func (m *testMetrics) ObserveRetry(name, reason string) {
+	m.retries = append(m.retries, name+":"+reason)
+}
+
+func TestInstrumentation(t *testing.T) {
+	body := `{"code":101,"error":"object not found"}`
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("Traceparent"); h != "00-trace-span-01" {
+			t.Errorf("expected trace context to be propagated. got [%s]", h)
+		}
+		w.WriteHeader(404)
+		fmt.Fprint(w, body)
+	})
+	defer teardownTestServer()
+
+	tr := &testTracer{}
+	m := &testMetrics{}
+	cli := *testClient
+	cli.SetInstrumentation(tr, m)
+
+	q, _ := cli.NewQuery(&User{})
+	err := q.Get("abc")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("expected 1 span. got [%d]", len(tr.spans))
+	}
+
+	s := tr.spans[0]
+	if s.name != "parse.get" || !s.ended {
+		t.Errorf("unexpected span: %+v", s)
+	}
+
+	expected := map[string]interface{}{
+		"parse.operation":           "get",
+		"parse.class_name":          "_User",
+		"http.request.method":       "GET",
+		"http.response.status_code": 404,
+		"parse.error_code":          101,
+	}
+	for k, v := range expected {
+		if s.attrs[k] != v {
+			t.Errorf("unexpected value for span attribute [%s]. got [%v] expected [%v]", k, s.attrs[k], v)
+		}
+	}
+	if apiErr, ok := s.err.(APIError); !ok || apiErr.Code() != 101 {
+		t.Errorf("expected span to end with APIError. got [%v]", s.err)
+	}
+
+	if m.requests != 1 || m.status != 404 || m.size != len(body) {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestInstrumentationRateLimitWait(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	m := &testMetrics{}
+	cli := *testClient
+	cli.SetInstrumentation(nil, m)
+	r := NewRateLimiter(50, 1)
+	defer r.Stop()
+	cli.SetRateLimiter(r)
+
+	for i := 0; i < 2; i++ {
+		q, _ := cli.NewQuery(&User{})
+		if err := q.Get("abc"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Only the second request should have waited for a token
+	if len(m.waits) != 1 || m.waits[0] <= 0 {
+		t.Errorf("expected a single rate limit wait to be observed. got %v", m.waits)
+	}
+}
@@ -0,0 +1,166 @@
+package parse
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// An interface for receiving debug logs of requests and responses. Arguments
+// following msg are alternating keys and values. *slog.Logger from the
+// standard library satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+const redacted = "[REDACTED]"
+
+// Fields that are always redacted from logged URLs and bodies. Auth data is
+// redacted in full, and the OAuth and MFA fields are listed as well since
+// they also appear outside of it, e.g. MFA recovery codes in authDataResponse.
+var defaultRedactedFields = []string{
+	"password", "sessionToken", "authData",
+	"access_token", "id_token",
+	"secret", "token", "recovery",
+}
+
+// Headers whose values are always redacted from logs
+var redactedHeaders = []string{
+	RestKeyHeader, MasterKeyHeader, SessionTokenHeader,
+	ClientKeyHeader, JavascriptKeyHeader, MaintenanceKeyHeader,
+}
+
+type requestLogger struct {
+	l      Logger
+	fields map[string]struct{}
+}
+
+// Log every request sent to Parse and every response received at debug level
+// to l. Pass a nil Logger to disable logging.
+//
+// API keys, session tokens, passwords, auth data, OAuth tokens and MFA
+// secrets, codes and recovery codes are always redacted. Any additional fields
+// (e.g. PII such as "email" or "phone") may be specified by name with
+// redactFields. Fields are redacted from query parameters and from JSON request
+// and response bodies at any depth. Field names are matched case-insensitively.
+func (c *Client) SetLogger(l Logger, redactFields ...string) {
+	if l == nil {
+		c.logger = nil
+		return
+	}
+
+	rl := &requestLogger{l: l, fields: map[string]struct{}{}}
+	for _, f := range append(defaultRedactedFields, redactFields...) {
+		rl.fields[strings.ToLower(f)] = struct{}{}
+	}
+	c.logger = rl
+}
+
+// Wraps next with logging of requests and responses
+func (rl *requestLogger) wrap(next Handler) Handler {
+	return func(o *Operation) (*Response, error) {
+		args := []interface{}{
+			"operation", o.Name,
+			"class", o.ClassName,
+			"method", o.Method,
+			"url", rl.redactURL(o.URL),
+			"header", rl.redactHeader(o.Header),
+		}
+		if o.Body != "" {
+			args = append(args, "body", rl.redactBody(o.Body))
+		}
+		rl.l.Debug("parse: request", args...)
+
+		start := time.Now()
+		resp, err := next(o)
+
+		args = []interface{}{
+			"operation", o.Name,
+			"class", o.ClassName,
+			"duration", time.Since(start),
+		}
+		if err != nil {
+			rl.l.Debug("parse: request failed", append(args, "error", err)...)
+		} else {
+			rl.l.Debug("parse: response", append(args, "status", resp.StatusCode, "body", rl.redactBody(string(resp.Body)))...)
+		}
+		return resp, err
+	}
+}
+
+func (rl *requestLogger) redacts(field string) bool {
+	_, ok := rl.fields[strings.ToLower(field)]
+	return ok
+}
+
+func (rl *requestLogger) redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	v := u.Query()
+	for k, vs := range v {
+		if rl.redacts(k) {
+			v[k] = []string{redacted}
+			continue
+		}
+		// Parameters such as where hold JSON documents, which may contain
+		// redacted fields themselves
+		for i, e := range vs {
+			if strings.HasPrefix(e, "{") || strings.HasPrefix(e, "[") {
+				vs[i] = rl.redactBody(e)
+			}
+		}
+	}
+
+	ru := *u
+	ru.RawQuery = v.Encode()
+	return ru.String()
+}
+
+func (rl *requestLogger) redactHeader(h http.Header) http.Header {
+	rh := http.Header{}
+	for k, v := range h {
+		rh[k] = v
+	}
+	for _, k := range redactedHeaders {
+		if rh.Get(k) != "" {
+			rh.Set(k, redacted)
+		}
+	}
+	return rh
+}
+
+// Redacts fields from the JSON document b. Bodies that are not JSON are
+// returned unmodified
+func (rl *requestLogger) redactBody(b string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(b), &v); err != nil {
+		return b
+	}
+
+	if rb, err := json.Marshal(rl.redactValue(v)); err == nil {
+		return string(rb)
+	}
+	return b
+}
+
+func (rl *requestLogger) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if rl.redacts(k) {
+				t[k] = redacted
+			} else {
+				t[k] = rl.redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = rl.redactValue(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,168 @@
+package parse
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var _ Logger = (*slog.Logger)(nil)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"sessionToken":"secret_token","username":"kylemcc","email":"kylemcc@gmail.com","createdAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	cli := *testClient
+	cli.SetLogger(l, "email")
+	cli.SetHeaders(RequestHeaders{
+		ClientKey:      "secret_client_key",
+		JavascriptKey:  "secret_js_key",
+		MaintenanceKey: "secret_maintenance_key",
+	})
+
+	if _, err := cli.Login("kylemcc", "secret_password", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(l.lines) != 2 {
+		t.Fatalf("expected a request and response to be logged. got: %v", l.lines)
+	}
+
+	out := strings.Join(l.lines, "\n")
+	for _, s := range []string{"secret_password", "secret_token", "kylemcc@gmail.com", "rest_key", "secret_client_key", "secret_js_key", "secret_maintenance_key"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected [%s] to be redacted from logs. got:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{"kylemcc", redacted, "status200"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected logs to contain [%s]. got:\n%s", s, out)
+		}
+	}
+}
+
+func TestLoggerRedactsRequestBody(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"objectId":"abc","createdAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	cli := *testClient
+	cli.SetLogger(l)
+
+	if err := cli.Signup("kylemcc", "secret_password", &User{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := strings.Join(l.lines, "\n")
+	if strings.Contains(out, "secret_password") {
+		t.Errorf("expected password to be redacted from logs. got:\n%s", out)
+	}
+	if !strings.Contains(out, `"password":"`+redacted+`"`) {
+		t.Errorf("expected request body to be logged. got:\n%s", out)
+	}
+}
+
+func TestLoggerRedactsOAuthTokens(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"objectId":"abc","sessionToken":"session_token","authData":{"google":{"id":"g1","id_token":"secret_id_token","access_token":"secret_google_token"}}}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	cli := *testClient
+	cli.SetLogger(l)
+
+	if _, err := cli.LoginGoogle(&GoogleAuthData{Id: "g1", IdToken: "secret_id_token", AccessToken: "secret_google_token"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cli.LoginGitHub(&GitHubAuthData{Id: "gh1", AccessToken: "secret_github_token"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := strings.Join(l.lines, "\n")
+	for _, s := range []string{"secret_id_token", "secret_google_token", "secret_github_token"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected [%s] to be redacted from logs. got:\n%s", s, out)
+		}
+	}
+	if !strings.Contains(out, `"authData":"`+redacted+`"`) {
+		t.Errorf("expected auth data to be redacted. got:\n%s", out)
+	}
+}
+
+func TestLoggerRedactsMFAEnrollment(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z","authDataResponse":{"mfa":{"recovery":"secret_recovery_1, secret_recovery_2"}}}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	cli := *testClient
+	cli.SetLogger(l)
+
+	s := &session{client: &cli, user: &User{Base: Base{Id: "user1"}, Username: "kylemcc"}, sessionToken: "abcd"}
+	e, err := s.EnrollMFA("parse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.EnableMFA(e, "123456"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(l.lines) != 2 {
+		t.Fatalf("expected a request and response to be logged. got: %v", l.lines)
+	}
+
+	out := strings.Join(l.lines, "\n")
+	for _, s := range []string{e.Secret, "123456", "secret_recovery_1", "secret_recovery_2"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected [%s] to be redacted from logs. got:\n%s", s, out)
+		}
+	}
+}
+
+func TestLoggerRedactsQueryParameters(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[]}`)
+	})
+	defer teardownTestServer()
+
+	l := &testLogger{}
+	cli := *testClient
+	cli.SetLogger(l, "email")
+
+	q, _ := cli.NewQuery(&User{})
+	q.EqualTo("email", "secret_email")
+	q.EqualTo("city", "Chicago")
+	q.First()
+
+	sessions := []SessionObject{}
+	q, _ = cli.NewQuery(&sessions)
+	q.EqualTo("sessionToken", "secret_token")
+	q.Find()
+
+	out := strings.Join(l.lines, "\n")
+	for _, s := range []string{"secret_email", "secret_token"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected [%s] to be redacted from logs. got:\n%s", s, out)
+		}
+	}
+	if !strings.Contains(out, "Chicago") {
+		t.Errorf("expected other query constraints to be logged. got:\n%s", out)
+	}
+}
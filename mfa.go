@@ -0,0 +1,169 @@
+package parse
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	// Returned when logging in as a user with MFA enabled without a TOTP code
+	ErrMFARequired = errors.New("parse: multi-factor authentication code required")
+
+	// Returned when a TOTP code or recovery code is not valid
+	ErrInvalidMFAToken = errors.New("parse: invalid multi-factor authentication code")
+)
+
+// Converts errors returned by Parse Server's MFA adapter to ErrMFARequired or
+// ErrInvalidMFAToken. The adapter does not use distinct error codes, so errors
+// are identified by their message. Other errors are returned unchanged.
+func mfaError(err error) error {
+	apiErr, ok := err.(APIError)
+	if !ok {
+		return err
+	}
+
+	msg := strings.ToLower(apiErr.Message())
+	switch {
+	case strings.Contains(msg, "missing additional authdata mfa"):
+		return ErrMFARequired
+	case strings.Contains(msg, "invalid mfa token"):
+		return ErrInvalidMFAToken
+	}
+	return err
+}
+
+// The settings needed to add a TOTP account to an authenticator app. See
+// Session.EnrollMFA.
+type MFAEnrollment struct {
+	// The base32 encoded TOTP secret
+	Secret string
+
+	// An otpauth:// URI containing the secret, suitable for encoding in a QR code
+	URI string
+}
+
+// Log in as the user identified by the provided username and password, with
+// code as a TOTP code (or recovery code) for users with MFA enabled. Returns
+// ErrInvalidMFAToken if the code is not valid.
+//
+// Login returns ErrMFARequired for users with MFA enabled.
+func (c *Client) LoginMFA(username, password, code string, u interface{}) (Session, error) {
+	var user interface{}
+	if u == nil {
+		user = &User{}
+	} else if err := validateUser(u); err != nil {
+		return nil, err
+	} else {
+		user = u
+	}
+
+	r := &loginRequest{
+		username: username,
+		password: password,
+		authdata: &AuthData{MFA: &MFAAuthData{Token: code}},
+	}
+
+	s := &session{user: user, client: c}
+	if b, err := c.doRequest(r); err != nil {
+		return nil, mfaError(err)
+	} else if st, err := c.handleLoginResponse(b, s.user); err != nil {
+		return nil, err
+	} else {
+		s.sessionToken = st
+	}
+	return s, nil
+}
+
+func (s *session) EnrollMFA(issuer string) (*MFAEnrollment, error) {
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+
+	b, err := randomBytes(20)
+	if err != nil {
+		return nil, err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	label := issuer
+	if f := reflect.ValueOf(s.user).Elem().FieldByName("Username"); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+		if label != "" {
+			label += ":"
+		}
+		label += f.String()
+	}
+
+	v := url.Values{}
+	v["secret"] = []string{secret}
+	if issuer != "" {
+		v["issuer"] = []string{issuer}
+	}
+	v["algorithm"] = []string{"SHA1"}
+	v["digits"] = []string{"6"}
+	v["period"] = []string{"30"}
+	uri := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: v.Encode()}
+
+	return &MFAEnrollment{Secret: secret, URI: uri.String()}, nil
+}
+
+func (s *session) EnableMFA(e *MFAEnrollment, code string) ([]string, error) {
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+	if e == nil || e.Secret == "" {
+		return nil, errors.New("parse: MFA secret must not be empty")
+	}
+	if f := reflect.ValueOf(s.user).Elem().FieldByName("Id"); !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return nil, errors.New("parse: user Id field must not be empty")
+	}
+
+	// The response includes the recovery codes, which are deliberately not
+	// populated into the user
+	c := s.cli()
+	u := &updateRequest{
+		client: c,
+		inst:   s.user,
+		values: map[string]updateOp{
+			"authData": {UpdateType: opSet, Value: AuthData{MFA: &MFAAuthData{Secret: e.Secret, Token: code}}},
+		},
+		sess: s,
+	}
+	b, err := c.doRequest(u)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+	c.invalidateCache(s.user)
+
+	resp := struct {
+		AuthDataResponse struct {
+			MFA struct {
+				Recovery interface{} `json:"recovery"`
+			} `json:"mfa"`
+		} `json:"authDataResponse"`
+	}{}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+
+	// Parse Server returns recovery codes as a comma separated string
+	var recovery []string
+	switch r := resp.AuthDataResponse.MFA.Recovery.(type) {
+	case string:
+		for _, c := range strings.Split(r, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				recovery = append(recovery, c)
+			}
+		}
+	case []interface{}:
+		for _, c := range r {
+			if cs, ok := c.(string); ok {
+				recovery = append(recovery, cs)
+			}
+		}
+	}
+	return recovery, nil
+}
@@ -0,0 +1,130 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLoginMFA(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/login" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body := struct {
+			Username string
+			Password string
+			AuthData AuthData
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error decoding request body: %v", err)
+		}
+		if body.Username != "username" || body.Password != "password" {
+			t.Errorf("login request did not include credentials. got [%+v]", body)
+		}
+		if body.AuthData.MFA == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":-1,"error":"Missing additional authData mfa"}`)
+			return
+		}
+		if body.AuthData.MFA.Token != "123456" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":-1,"error":"Invalid MFA token"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"objectId":"user1","sessionToken":"abcd","username":"username"}`)
+	})
+	defer teardownTestServer()
+
+	if _, err := testClient.Login("username", "password", nil); err != ErrMFARequired {
+		t.Errorf("expected ErrMFARequired. got [%v]", err)
+	}
+	if _, err := testClient.LoginMFA("username", "password", "000000", nil); err != ErrInvalidMFAToken {
+		t.Errorf("expected ErrInvalidMFAToken. got [%v]", err)
+	}
+
+	s, err := testClient.LoginMFA("username", "password", "123456", nil)
+	if err != nil {
+		t.Errorf("unexpected error on login: %v", err)
+		t.FailNow()
+	}
+	if st := s.(*session).sessionToken; st != "abcd" {
+		t.Errorf("login did not set a proper session token. got [%v] expected [%v]", st, "abcd")
+	}
+}
+
+func TestEnrollMFA(t *testing.T) {
+	user := &User{Base: Base{Id: "user1"}, Username: "kylemcc"}
+	s := &session{client: testClient, user: user, sessionToken: "abcd"}
+
+	e, err := s.EnrollMFA("My App")
+	if err != nil {
+		t.Errorf("unexpected error enrolling in MFA: %v", err)
+		t.FailNow()
+	}
+	if len(e.Secret) != 32 {
+		t.Errorf("expected a 32 character base32 secret. got [%v]", e.Secret)
+	}
+
+	uri, err := url.Parse(e.URI)
+	if err != nil {
+		t.Errorf("unexpected error parsing URI: %v", err)
+		t.FailNow()
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/My App:kylemcc" {
+		t.Errorf("wrong otpauth URI. got [%v]", e.URI)
+	}
+	expected := url.Values{
+		"secret":    {e.Secret},
+		"issuer":    {"My App"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}
+	if q := uri.Query(); !reflect.DeepEqual(q, expected) {
+		t.Errorf("wrong otpauth parameters. got [%v] expected [%v]", q, expected)
+	}
+}
+
+func TestEnableMFA(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/1/users/user1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "abcd" {
+			t.Errorf("request had wrong session token. got [%v] expected [%v]", h, "abcd")
+		}
+
+		body := map[string]map[string]map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mfa := body["authData"]["mfa"]
+		if mfa["secret"] != "SECRET" {
+			t.Errorf("wrong secret sent. got [%v] expected [%v]", mfa["secret"], "SECRET")
+		}
+		if mfa["token"] != "123456" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":-1,"error":"Invalid MFA token"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z","authDataResponse":{"mfa":{"recovery":"abc123, def456"}}}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{Base: Base{Id: "user1"}}, sessionToken: "abcd"}
+	e := &MFAEnrollment{Secret: "SECRET"}
+
+	if _, err := s.EnableMFA(e, "000000"); err != ErrInvalidMFAToken {
+		t.Errorf("expected ErrInvalidMFAToken. got [%v]", err)
+	}
+
+	codes, err := s.EnableMFA(e, "123456")
+	if err != nil {
+		t.Errorf("unexpected error enabling MFA: %v", err)
+	}
+	if expected := []string{"abc123", "def456"}; !reflect.DeepEqual(codes, expected) {
+		t.Errorf("wrong recovery codes. got [%v] expected [%v]", codes, expected)
+	}
+}
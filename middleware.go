@@ -0,0 +1,121 @@
+package parse
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Describes a single request to the Parse REST API as seen by Middleware.
+//
+// Name identifies the high-level operation being performed, and is one of:
+// "get", "find", "count", "create", "signup", "update", "delete", "function",
+// "push", "login", "logout", "upgradeSession", "me", "requestPasswordReset",
+// "verificationEmailRequest", "verifyPassword" or "config".
+//
+// Middleware may modify Header, URL and Body before passing the operation to
+// the next Handler. Middleware may also replace Context, e.g. to apply a
+// deadline to the request.
+type Operation struct {
+	Name      string
+	ClassName string
+	Method    string
+	Endpoint  string
+	URL       *url.URL
+	Header    http.Header
+	Body      string
+
+	// Whether the request is authorized with the Master Key
+	UseMasterKey bool
+
+	// Whether the request is made on behalf of a user with a session token
+	HasSessionToken bool
+
+	// The context of the request. Waiting on the rate and concurrency
+	// limiters, as well as the request itself, is abandoned once it is done.
+	Context context.Context
+}
+
+// A response from the Parse REST API. Body contains the decompressed response body.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// A Handler performs an Operation and returns its response. Non-2xx responses
+// are not errors at this level; they are converted to an APIError once the
+// response has passed back through all Middleware.
+type Handler func(o *Operation) (*Response, error)
+
+// Middleware wraps a Handler, and may act on an Operation before and after
+// calling next. It may also return a Response without calling next at all
+// to short-circuit the request.
+//
+// E.g., to record the duration of every request:
+//
+//	cli.Use(func(next parse.Handler) parse.Handler {
+//		return func(o *parse.Operation) (*parse.Response, error) {
+//			start := time.Now()
+//			resp, err := next(o)
+//			log.Printf("%s %s took %v", o.Name, o.ClassName, time.Since(start))
+//			return resp, err
+//		}
+//	})
+type Middleware func(next Handler) Handler
+
+// Add Middleware to the chain invoked for every request made by this client.
+// Middleware is called in the order it was added, so the first Middleware
+// added is the outermost one.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// Returns the name of the operation represented by op, and the name of the
+// class it applies to, if any
+func describe(op request) (name, className string) {
+	switch r := op.(type) {
+	case *query:
+		className = getElemClassName(r.inst)
+		if r.op == otGet {
+			name = "get"
+		} else if r.count != nil {
+			name = "count"
+		} else {
+			name = "find"
+		}
+	case *createRequest:
+		className = getClassName(r.v)
+		if r.isUser {
+			name = "signup"
+		} else {
+			name = "create"
+		}
+	case *updateRequest:
+		name, className = "update", getClassName(r.inst)
+	case *deleteRequest:
+		name, className = "delete", getClassName(r.inst)
+	case *callFnRequest:
+		name = "function"
+	case *pushRequest:
+		name = "push"
+	case *loginRequest:
+		className = "_User"
+		if r.s != nil {
+			name = "me"
+		} else {
+			name = "login"
+		}
+	case *configRequest:
+		name = "config"
+	case *logoutRequest:
+		name, className = "logout", "_Session"
+	case *emailRequest:
+		name, className = r.ep, "_User"
+	case *verifyPasswordRequest:
+		name, className = "verifyPassword", "_User"
+	case *upgradeSessionRequest:
+		name, className = "upgradeSession", "_Session"
+	}
+	return name, className
+}
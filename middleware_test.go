@@ -0,0 +1,90 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMiddlewareSeesOperation(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("X-Custom"); h != "value" {
+			t.Errorf("expected middleware header to be sent. got [%s]", h)
+		}
+		fmt.Fprintf(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	var calls []string
+	var seen Operation
+	var status int
+	cli := *testClient
+	cli.Use(func(next Handler) Handler {
+		return func(o *Operation) (*Response, error) {
+			calls = append(calls, "outer")
+			seen = *o
+			o.Header.Set("X-Custom", "value")
+			resp, err := next(o)
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			return resp, err
+		}
+	}, func(next Handler) Handler {
+		return func(o *Operation) (*Response, error) {
+			calls = append(calls, "inner")
+			return next(o)
+		}
+	})
+
+	q, _ := cli.NewQuery(&User{})
+	q.SetSessionToken("session_token")
+	if err := q.Get("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"outer", "inner"}) {
+		t.Errorf("middleware called in wrong order: %v", calls)
+	}
+	if seen.Name != "get" || seen.ClassName != "_User" || seen.Method != "GET" || seen.Endpoint != "users/abc" {
+		t.Errorf("unexpected operation: %+v", seen)
+	}
+	if seen.UseMasterKey || !seen.HasSessionToken {
+		t.Errorf("unexpected credentials on operation: %+v", seen)
+	}
+	if status != 200 {
+		t.Errorf("expected middleware to see response status 200. got [%d]", status)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should not have been sent")
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.Use(func(next Handler) Handler {
+		return func(o *Operation) (*Response, error) {
+			if o.Name == "delete" {
+				return &Response{StatusCode: 400, Body: []byte(`{"code":101,"error":"object not found for delete"}`)}, nil
+			}
+			return &Response{StatusCode: 200, Body: []byte(`{"objectId":"abc","username":"canned"}`)}, nil
+		}
+	})
+
+	u := User{}
+	q, _ := cli.NewQuery(&u)
+	if err := q.Get("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Username != "canned" {
+		t.Errorf("expected canned response to be decoded. got: %+v", u)
+	}
+
+	err := cli.Delete(&u, false)
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code() != 101 {
+		t.Errorf("expected canned error response to be returned as APIError. got [%v]", err)
+	}
+}
@@ -3,7 +3,6 @@ package parse
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"time"
 )
 
@@ -41,6 +40,7 @@ type pushRequest struct {
 	client *Client
 
 	shouldUseMasterKey bool
+	sess               *session
 	channels           []string
 	expirationInterval int64
 	expirationTime     *Date
@@ -96,12 +96,11 @@ func (p *pushRequest) Data(d map[string]interface{}) PushNotification {
 
 func (p *pushRequest) Send() error {
 	b, err := p.client.doRequest(p)
-	data := map[string]interface{}{}
-	if err := json.Unmarshal(b, &data); err != nil {
+	if err != nil {
 		return err
 	}
-	fmt.Printf("data: %v\n", data)
-	return err
+	data := map[string]interface{}{}
+	return json.Unmarshal(b, &data)
 }
 
 func (p *pushRequest) method() string {
@@ -133,7 +132,6 @@ func (p *pushRequest) body() (string, error) {
 		Where:              p.where,
 	})
 
-	fmt.Printf("body: %s\n", payload)
 	return string(payload), err
 }
 
@@ -142,7 +140,14 @@ func (p *pushRequest) useMasterKey() bool {
 }
 
 func (p *pushRequest) sessionToken() string {
-	return ""
+	if p.sess == nil {
+		return ""
+	}
+	return p.sess.currentToken()
+}
+
+func (p *pushRequest) boundSession() *session {
+	return p.sess
 }
 
 func (p *pushRequest) contentType() string {
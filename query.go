@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"path"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 )
 
 type opType int
@@ -32,6 +32,10 @@ type Query interface {
 	// Set the session token for the given request.
 	SetSessionToken(st string)
 
+	// Set headers to send with this query, in addition to those set for the
+	// client
+	SetHeaders(h RequestHeaders)
+
 	// Get retrieves the instance of the type pointed to by v and
 	// identified by id, and stores the result in v.
 	Get(id string) error
@@ -194,6 +198,10 @@ type Query interface {
 
 	SetBatchSize(size uint)
 
+	// Report unknown fields, type mismatches and missing required fields in
+	// the results of this query as a *DecodeError. See Client.SetStrictDecoding.
+	StrictDecoding()
+
 	// Retrieve objects that are members of Relation field of a parent object.
 	//
 	// E.g.:
@@ -215,6 +223,11 @@ type Query interface {
 	// Retrieves a list of objects that satisfy the given query. The results
 	// are assigned to the slice provided to NewQuery.
 	//
+	// Results are decoded as the response is read, without holding the whole
+	// response in memory, unless the client has Middleware, a Logger,
+	// instrumentation, a query cache or request coalescing, all of which need
+	// the complete response.
+	//
 	// E.g.:
 	//
 	// cli := parse.NewClient("APP_ID", "REST_KEY", "MASTER_KEY", "HOST", "PATH")
@@ -263,7 +276,10 @@ type query struct {
 	className string
 
 	st                 string
+	sess               *session
+	hdrs               RequestHeaders
 	shouldUseMasterKey bool
+	strict             bool
 }
 
 // Create a new query instance.
@@ -290,16 +306,17 @@ func (q *query) UseMasterKey() {
 
 func (q *query) SetSessionToken(st string) {
 	q.st = st
+	q.sess = nil
+}
+
+func (q *query) SetHeaders(h RequestHeaders) {
+	q.hdrs = h
 }
 
 func (q *query) Get(id string) error {
 	q.op = otGet
 	q.instId = &id
-	if body, err := q.client.doRequest(q); err != nil {
-		return err
-	} else {
-		return handleResponse(body, q.inst)
-	}
+	return q.client.doQueryRequest(q, q.decodeInto(q.inst))
 }
 
 func (q *query) OrderBy(fs ...string) {
@@ -346,14 +363,7 @@ func (q *query) NotEqualTo(f string, v interface{}) {
 }
 
 func (q *query) GreaterThan(f string, v interface{}) {
-	var qv interface{}
-	if t, ok := v.(time.Time); ok {
-		qv = Date(t)
-	} else if t, ok := v.(*time.Time); ok {
-		qv = Date(*t)
-	} else {
-		qv = v
-	}
+	qv := encodeForRequest(v)
 
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
@@ -368,14 +378,7 @@ func (q *query) GreaterThan(f string, v interface{}) {
 }
 
 func (q *query) GreaterThanOrEqual(f string, v interface{}) {
-	var qv interface{}
-	if t, ok := v.(time.Time); ok {
-		qv = Date(t)
-	} else if t, ok := v.(*time.Time); ok {
-		qv = Date(*t)
-	} else {
-		qv = v
-	}
+	qv := encodeForRequest(v)
 
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
@@ -390,14 +393,7 @@ func (q *query) GreaterThanOrEqual(f string, v interface{}) {
 }
 
 func (q *query) LessThan(f string, v interface{}) {
-	var qv interface{}
-	if t, ok := v.(time.Time); ok {
-		qv = Date(t)
-	} else if t, ok := v.(*time.Time); ok {
-		qv = Date(*t)
-	} else {
-		qv = v
-	}
+	qv := encodeForRequest(v)
 
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
@@ -412,14 +408,7 @@ func (q *query) LessThan(f string, v interface{}) {
 }
 
 func (q *query) LessThanOrEqual(f string, v interface{}) {
-	var qv interface{}
-	if t, ok := v.(time.Time); ok {
-		qv = Date(t)
-	} else if t, ok := v.(*time.Time); ok {
-		qv = Date(*t)
-	} else {
-		qv = v
-	}
+	qv := encodeForRequest(v)
 
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
@@ -436,26 +425,26 @@ func (q *query) LessThanOrEqual(f string, v interface{}) {
 func (q *query) In(f string, vs ...interface{}) {
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
-			m["$in"] = vs
+			m["$in"] = encodeForRequest(vs)
 		}
 		return
 	}
 
 	q.where[f] = map[string]interface{}{
-		"$in": vs,
+		"$in": encodeForRequest(vs),
 	}
 }
 
 func (q *query) NotIn(f string, vs ...interface{}) {
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
-			m["$nin"] = vs
+			m["$nin"] = encodeForRequest(vs)
 		}
 		return
 	}
 
 	q.where[f] = map[string]interface{}{
-		"$nin": vs,
+		"$nin": encodeForRequest(vs),
 	}
 }
 
@@ -488,13 +477,13 @@ func (q *query) DoesNotExist(f string) {
 func (q *query) All(f string, vs ...interface{}) {
 	if cv, ok := q.where[f]; ok {
 		if m, ok := cv.(map[string]interface{}); ok {
-			m["$all"] = vs
+			m["$all"] = encodeForRequest(vs)
 		}
 		return
 	}
 
 	q.where[f] = map[string]interface{}{
-		"$all": vs,
+		"$all": encodeForRequest(vs),
 	}
 }
 
@@ -651,8 +640,11 @@ func (q *query) Clone() Query {
 		op:                 q.op,
 		instId:             q.instId,
 		st:                 q.st,
+		sess:               q.sess,
+		hdrs:               q.hdrs,
 		className:          q.className,
 		shouldUseMasterKey: q.shouldUseMasterKey,
+		strict:             q.strict,
 	}
 
 	if q.limit != nil {
@@ -744,16 +736,17 @@ func (q *query) Each(rc interface{}) (*Iterator, error) {
 	}
 
 	i := newIterator()
+	i.iterating = true
 
 	go func() {
 		defer func() {
+			i.mu.Lock()
+			i.iterating = false
+			i.mu.Unlock()
 			rv.Close()
 			close(i.resChan)
-			i.iterating = false
 		}()
 
-		i.iterating = true
-
 		var sliceType reflect.Type
 		if rt == chanInterfaceType {
 			sliceType = reflect.SliceOf(instType)
@@ -784,15 +777,12 @@ func (q *query) Each(rc interface{}) (*Iterator, error) {
 			s.Elem().Set(reflect.MakeSlice(sliceType, 0, *q.limit))
 
 			// TODO: handle errors and retry if possible
-			b, err := q.client.doRequest(q)
-			if err != nil {
-				i.err = err
-				i.resChan <- err
-				return
-			}
-
-			if err := handleResponse(b, s.Interface()); err != nil && err != ErrNoRows {
-				i.err = err
+			// Results are not tracked, as there may be any number of them
+			err := q.client.doRequestDecode(q, func(r io.Reader) error {
+				return q.decode(r, s.Interface())
+			})
+			if err != nil && err != ErrNoRows {
+				i.setError(err)
 				i.resChan <- err
 				return
 			}
@@ -824,6 +814,31 @@ func (q *query) Each(rc interface{}) (*Iterator, error) {
 	return i, nil
 }
 
+func (q *query) StrictDecoding() {
+	q.strict = true
+}
+
+// Returns a function populating dst from a response body as decode does,
+// tracking the results if the client has a Tracker
+func (q *query) decodeInto(dst interface{}) func(r io.Reader) error {
+	return func(r io.Reader) error {
+		err := q.decode(r, dst)
+		if err == nil && q.client.tracker != nil {
+			q.client.tracker.trackAll(dst)
+		}
+		return err
+	}
+}
+
+// Populates dst from the response body read from r, in strict mode if enabled
+// for this query or its client
+func (q *query) decode(r io.Reader, dst interface{}) error {
+	d := q.client.decoder()
+	d.strict = q.strict || q.client.strictDecoding
+	d.partial = len(q.keys) > 0
+	return d.decodeResponse(r, dst)
+}
+
 func (q *query) SetBatchSize(size uint) {
 	if size <= 1000 {
 		q.batchSize = int(size)
@@ -838,11 +853,7 @@ func (q *query) RelatedTo(f string, v interface{}) {
 
 func (q *query) Find() error {
 	q.op = otQuery
-	if b, err := q.client.doRequest(q); err != nil {
-		return err
-	} else {
-		return handleResponse(b, q.inst)
-	}
+	return q.client.doQueryRequest(q, q.decodeInto(q.inst))
 }
 
 func (q *query) First() error {
@@ -857,9 +868,7 @@ func (q *query) First() error {
 		dv := reflect.New(reflect.SliceOf(rvi.Type()))
 		dv.Elem().Set(reflect.MakeSlice(reflect.SliceOf(rvi.Type()), 0, 1))
 
-		if b, err := q.client.doRequest(q); err != nil {
-			return err
-		} else if err := handleResponse(b, dv.Interface()); err != nil {
+		if err := q.client.doQueryRequest(q, q.decodeInto(dv.Interface())); err != nil {
 			return err
 		}
 
@@ -868,9 +877,7 @@ func (q *query) First() error {
 			rv.Elem().Set(dv.Elem().Index(0))
 		}
 	} else if rvi.Kind() == reflect.Slice {
-		if b, err := q.client.doRequest(q); err != nil {
-			return err
-		} else if err := handleResponse(b, q.inst); err != nil {
+		if err := q.client.doQueryRequest(q, q.decodeInto(q.inst)); err != nil {
 			return err
 		}
 	} else {
@@ -886,12 +893,8 @@ func (q *query) Count() (int64, error) {
 	q.count = &c
 
 	var count int64
-	if b, err := q.client.doRequest(q); err != nil {
-		return 0, err
-	} else {
-		err := handleResponse(b, &count)
-		return count, err
-	}
+	err := q.client.doQueryRequest(q, q.decodeInto(&count))
+	return count, err
 }
 
 func (q *query) payload() (string, error) {
@@ -972,9 +975,20 @@ func (q *query) useMasterKey() bool {
 }
 
 func (q *query) sessionToken() string {
+	if q.sess != nil {
+		return q.sess.currentToken()
+	}
 	return q.st
 }
 
+func (q *query) boundSession() *session {
+	return q.sess
+}
+
+func (q *query) headers() RequestHeaders {
+	return q.hdrs
+}
+
 func (q *query) contentType() string {
 	return "application/x-www-form-urlencoded"
 }
@@ -1038,16 +1052,24 @@ func newIterator() *Iterator {
 // Returns the terminal error value of the iteration process, or nil if
 // the iteration process exited normally (or hasn't started yet)
 func (i *Iterator) Error() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	return i.err
 }
 
+func (i *Iterator) setError(err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.err = err
+}
+
 // Cancel interating over the current query. This is a no-op if iteration has
 // already terminated
 func (i *Iterator) Cancel() {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 	if i.iterating {
-		i.cancel <- 1
+		i.requestCancel()
 	}
 }
 
@@ -1058,7 +1080,16 @@ func (i *Iterator) CancelError(err error) {
 	defer i.mu.Unlock()
 	if i.iterating {
 		i.err = err
-		i.cancel <- 1
+		i.requestCancel()
+	}
+}
+
+// Signal the iterating goroutine to stop, unless a cancellation is already
+// pending. Must be called with i.mu held.
+func (i *Iterator) requestCancel() {
+	select {
+	case i.cancel <- 1:
+	default:
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -557,6 +558,44 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestHandleResponseResults(t *testing.T) {
+	cases := []struct {
+		body        string
+		expectedIds []string
+		expectedErr error
+	}{
+		{`{"results":[{"objectId":"123"},{"objectId":"abc"}]}`, []string{"123", "abc"}, nil},
+		{`{"other":{"results":[]},"results":[{"objectId":"123"}],"more":1}`, []string{"123"}, nil},
+		{`{"results":[]}`, []string{}, ErrNoRows},
+		{`{"results":null}`, []string{}, nil},
+	}
+
+	for _, tc := range cases {
+		us := []*User{{Username: "stale"}}
+		err := testClient.handleResponse([]byte(tc.body), &us)
+		if err != tc.expectedErr {
+			t.Errorf("unexpected error for %s. got [%v] expected [%v]", tc.body, err, tc.expectedErr)
+		}
+		if len(us) != len(tc.expectedIds) {
+			t.Errorf("wrong number of results for %s. got [%d] expected [%d]", tc.body, len(us), len(tc.expectedIds))
+			continue
+		}
+		for i, id := range tc.expectedIds {
+			if us[i].Id != id {
+				t.Errorf("wrong result at index %d for %s. got [%s] expected [%s]", i, tc.body, us[i].Id, id)
+			}
+		}
+	}
+
+	us := []User{}
+	if err := testClient.handleResponse([]byte(`{"results":[1]}`), &us); err == nil {
+		t.Errorf("expected an error decoding a malformed result")
+	}
+	if err := testClient.handleResponse([]byte(`[]`), &us); err == nil {
+		t.Errorf("expected an error decoding a response that is not an object")
+	}
+}
+
 func TestGet(t *testing.T) {
 	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/1/users/abc123" {
@@ -605,7 +644,7 @@ func TestEach(t *testing.T) {
 			}
 		}
 		j, _ := json.Marshal(map[string]interface{}{"results": ret})
-		fmt.Fprintf(w, string(j))
+		fmt.Fprint(w, string(j))
 	})
 	defer teardownTestServer()
 
@@ -694,3 +733,88 @@ func TestGetQueryRepr(t *testing.T) {
 		}
 	}
 }
+
+func TestConcurrentQueries(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[{"objectId":"abc","username":"kylemcc","f1":{"__type":"Object","className":"ConcurrentType","a":1}}]}`)
+	})
+	defer teardownTestServer()
+
+	type ConcurrentType struct {
+		A int
+	}
+
+	type ConcurrentUser struct {
+		User
+		F1 interface{}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterType(new(ConcurrentType))
+		}()
+		go func() {
+			defer wg.Done()
+			users := []ConcurrentUser{}
+			q, _ := testClient.NewQuery(&users)
+			if err := q.Find(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if len(users) != 1 || users[0].Username != "kylemcc" {
+				t.Errorf("unexpected results: %+v", users)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Signals on decoded when a value is decoded into it
+type decodeSignal bool
+
+var decoded = make(chan struct{}, 1)
+
+func (s *decodeSignal) UnmarshalParse(v interface{}) error {
+	select {
+	case decoded <- struct{}{}:
+	default:
+	}
+	*s = true
+	return nil
+}
+
+type streamTestType struct {
+	Base
+	Signal decodeSignal `parse:"signal"`
+}
+
+func (s *streamTestType) ClassName() string {
+	return "Stream"
+}
+
+func TestFindDecodesWhileReading(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[{"objectId":"a","signal":true}`)
+		w.(http.Flusher).Flush()
+
+		// The rest of the page is only sent once the first result has been
+		// decoded, which requires the client not to wait for the whole body
+		select {
+		case <-decoded:
+		case <-time.After(time.Second):
+			t.Errorf("expected results to be decoded before the whole response was read")
+		}
+		fmt.Fprint(w, `,{"objectId":"b","signal":true}]}`)
+	})
+	defer teardownTestServer()
+
+	results := []streamTestType{}
+	q, _ := testClient.NewQuery(&results)
+	if err := q.Find(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || results[1].Id != "b" || !bool(results[1].Signal) {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
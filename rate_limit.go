@@ -1,28 +1,202 @@
 package parse
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Parse error code returned when an app exceeds its request limit
+const requestLimitExceeded = 155
 
 type limiter interface {
-	limit()
+	// Block until o may be sent or ctx is done, returning the time spent
+	// blocked. The returned function must be called once the request has
+	// completed, with the status and body of the response. status is 0 if no
+	// response was received.
+	wait(ctx context.Context, o *Operation) (done func(status int, body []byte), waited time.Duration, err error)
+}
+
+// A token bucket rate limiter for requests made to Parse.
+//
+// Tokens are added to the bucket at a constant rate up to a maximum of burst
+// tokens, and each request consumes one or more tokens. The bucket starts full,
+// so up to burst requests may be made immediately.
+//
+// When Parse responds with HTTP status 429 or error code 155 (request limit
+// exceeded), the rate is halved. It then recovers gradually with each
+// successful request, up to the configured rate.
+//
+// A RateLimiter is safe for concurrent use, and may be shared between clients.
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	maxRate  float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	weight   func(o *Operation) float64
+	stopped  bool
+	stopChan chan struct{}
+
+	// Whether a limiter created by SetRateLimit is also used by copies of
+	// the client made with WithHeaders, in which case it is not stopped
+	// when the client's rate limit is replaced
+	shared bool
+}
+
+// Create a new RateLimiter allowing rate requests per second, with bursts of
+// up to burst requests. rate may be fractional, e.g. 0.5 allows one request
+// every two seconds. A rate of 0 disables limiting. A burst less than 1 is
+// treated as 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	r := &RateLimiter{
+		stopChan: make(chan struct{}),
+		last:     time.Now(),
+	}
+	r.SetRate(rate, burst)
+	r.tokens = r.burst
+	return r
+}
+
+// Reconfigure the rate and burst of this limiter. Any tokens in excess of
+// the new burst are discarded.
+func (r *RateLimiter) SetRate(rate float64, burst int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refill(time.Now())
+	if burst < 1 {
+		burst = 1
+	}
+	r.rate = rate
+	r.maxRate = rate
+	r.burst = float64(burst)
+	r.tokens = math.Min(r.tokens, r.burst)
+}
+
+// Returns the current rate of this limiter in requests per second. This may
+// be lower than the configured rate after Parse has reported that the request
+// limit was exceeded.
+func (r *RateLimiter) Rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rate
+}
+
+// Set a function returning the number of tokens consumed by a request. By
+// default, every request consumes a single token. A weight less than or equal
+// to 0 exempts the request from rate limiting.
+func (r *RateLimiter) SetWeightFunc(fn func(o *Operation) float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.weight = fn
+}
+
+// Stop limiting requests. Any goroutines blocked in Wait are released, and
+// subsequent calls to Wait return immediately.
+func (r *RateLimiter) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped {
+		r.stopped = true
+		close(r.stopChan)
+	}
 }
 
-type rateLimiterT struct {
-	c chan time.Time
+// Block until n tokens are available, or until ctx is done. If ctx is done
+// first, the tokens are returned to the bucket and ctx.Err() is returned.
+func (r *RateLimiter) Wait(ctx context.Context, n float64) error {
+	_, err := r.reserve(ctx, n)
+	return err
 }
 
-func newRateLimiter(limit, burst uint) *rateLimiterT {
-	r := &rateLimiterT{
-		c: make(chan time.Time, burst),
+// Implements Wait, returning the time spent blocked
+func (r *RateLimiter) reserve(ctx context.Context, n float64) (time.Duration, error) {
+	if n <= 0 {
+		return 0, nil
 	}
-	go func() {
-		for t := range time.Tick(time.Second / time.Duration(limit)) {
-			r.c <- t
+
+	r.mu.Lock()
+	if r.stopped || r.rate <= 0 {
+		r.mu.Unlock()
+		return 0, nil
+	}
+
+	// Reserve the tokens now, allowing the bucket to go negative. This
+	// ensures callers are served in the order they arrive.
+	now := time.Now()
+	r.refill(now)
+	r.tokens -= n
+	var d time.Duration
+	if r.tokens < 0 {
+		d = time.Duration(-r.tokens / r.rate * float64(time.Second))
+	}
+	r.mu.Unlock()
+
+	if d == 0 {
+		return 0, nil
+	}
+
+	start := time.Now()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return time.Since(start), nil
+	case <-r.stopChan:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		r.mu.Lock()
+		r.tokens += n
+		r.mu.Unlock()
+		return time.Since(start), ctx.Err()
+	}
+}
+
+func (r *RateLimiter) wait(ctx context.Context, o *Operation) (func(int, []byte), time.Duration, error) {
+	n := 1.0
+	r.mu.Lock()
+	if r.weight != nil {
+		n = r.weight(o)
+	}
+	r.mu.Unlock()
+	waited, err := r.reserve(ctx, n)
+	return r.observe, waited, err
+}
+
+// Adjust the rate based on the response to a request
+func (r *RateLimiter) observe(status int, body []byte) {
+	if status == 0 {
+		return
+	}
+
+	throttled := status == http.StatusTooManyRequests
+	if !throttled && !(status >= 200 && status < 300) {
+		apiErr := apiError{}
+		if err := json.Unmarshal(body, &apiErr); err == nil {
+			throttled = apiErr.ErrorCode == requestLimitExceeded
 		}
-	}()
+	}
 
-	return r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refill(time.Now())
+	if throttled {
+		// Never slow down to less than one request per minute
+		r.rate = math.Max(r.rate/2, math.Min(r.maxRate, 1.0/60))
+	} else if r.rate < r.maxRate {
+		r.rate = math.Min(r.rate+r.maxRate/20, r.maxRate)
+	}
 }
 
-func (l *rateLimiterT) limit() {
-	<-l.c
+// Add tokens accumulated since the last refill. Must be called with r.mu held.
+func (r *RateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(r.last).Seconds(); elapsed > 0 {
+		r.tokens = math.Min(r.tokens+elapsed*r.rate, r.burst)
+	}
+	r.last = now
 }
@@ -0,0 +1,165 @@
+package parse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	r := NewRateLimiter(1, 5)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := r.Wait(context.Background(), 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("expected burst to be available immediately. took [%v]", d)
+	}
+}
+
+func TestRateLimiterFractionalRate(t *testing.T) {
+	r := NewRateLimiter(20, 1)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		r.Wait(context.Background(), 1)
+	}
+	// the first token is available immediately, the next two at 50ms intervals
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("expected requests to be limited. took [%v]", d)
+	}
+
+	r.SetRate(0.5, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("expected wait to be cancelled. got [%v]", err)
+	}
+}
+
+func TestRateLimiterStop(t *testing.T) {
+	r := NewRateLimiter(0.01, 1)
+	r.Wait(context.Background(), 1)
+
+	done := make(chan error)
+	go func() {
+		done <- r.Wait(context.Background(), 1)
+	}()
+
+	r.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected Stop to release blocked waiters")
+	}
+}
+
+func TestRateLimiterWeights(t *testing.T) {
+	r := NewRateLimiter(1, 10)
+	r.SetWeightFunc(func(o *Operation) float64 {
+		if o.Name == "count" {
+			return 10
+		}
+		return 1
+	})
+
+	r.wait(context.Background(), &Operation{Name: "count"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx, 1); err == nil {
+		t.Errorf("expected weighted operation to consume the entire burst")
+	}
+}
+
+func TestRateLimiterSlowsDownWhenThrottled(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, `{"code":155,"error":"request limit exceeded"}`)
+	})
+	defer teardownTestServer()
+
+	r := NewRateLimiter(100, 10)
+	cli := *testClient
+	cli.SetRateLimiter(r)
+
+	q, _ := cli.NewQuery(&User{})
+	if err := q.Get("abc"); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if rate := r.Rate(); rate != 50 {
+		t.Errorf("expected rate to be halved. got [%v]", rate)
+	}
+
+	r.observe(200, nil)
+	if rate := r.Rate(); rate != 55 {
+		t.Errorf("expected rate to recover. got [%v]", rate)
+	}
+}
+
+func TestRateLimitWaitHonorsRequestContext(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"objectId":"abc"}`)
+	})
+	defer teardownTestServer()
+
+	r := NewRateLimiter(0.1, 1)
+	defer r.Stop()
+	l := NewConcurrencyLimiter(1)
+
+	cli := *testClient
+	cli.SetRateLimiter(r)
+	cli.SetConcurrencyLimiter(l)
+	cli.Use(func(next Handler) Handler {
+		return func(o *Operation) (*Response, error) {
+			ctx, cancel := context.WithTimeout(o.Context, 20*time.Millisecond)
+			defer cancel()
+			o.Context = ctx
+			return next(o)
+		}
+	})
+
+	q, _ := cli.NewQuery(&User{})
+	if err := q.Get("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The second request waits for a token, without holding the only
+	// concurrency slot, until its deadline passes
+	errc := make(chan error, 1)
+	go func() {
+		q, _ := cli.NewQuery(&User{})
+		errc <- q.Get("abc")
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if done, _, err := l.wait(ctx, &Operation{Name: "get"}); err != nil {
+		t.Errorf("expected concurrency slot to be free while waiting on the rate limiter")
+	} else {
+		done(0, nil)
+	}
+
+	select {
+	case err := <-errc:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected context.DeadlineExceeded. got [%v]", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected rate limit wait to be abandoned at the request deadline")
+	}
+	if requests != 1 {
+		t.Errorf("expected only the first request to be sent. got [%d] requests", requests)
+	}
+}
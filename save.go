@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Parse error code returned when a unique field already holds a value
+const duplicateValue = 137
+
+// Save the instance of the type pointed to by v to the Parse database. If
+// v has no Id, it is created as with Create. Otherwise, every field that
+// would be sent when creating v is set with an update request. If
+// useMasterKey=true, the Master Key will be used for the request.
+//
+// Use NewTrackedUpdate to send only the fields that have changed.
+func (c *Client) Save(v interface{}, useMasterKey bool) error {
+	return c.save(v, useMasterKey, "")
+}
+
+func (c *Client) save(v interface{}, useMasterKey bool, sessionToken string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("parse: expected a non-nil pointer got %v", rv.Kind())
+	}
+
+	var id string
+	if f := rv.Elem().FieldByName("Id"); f.IsValid() && f.Kind() == reflect.String {
+		id = f.String()
+	}
+	if id == "" {
+		return c.create(v, useMasterKey, sessionToken)
+	}
+
+	fields, err := encodeFields(v)
+	if err != nil {
+		return err
+	}
+
+	u := &updateRequest{
+		client:             c,
+		inst:               v,
+		values:             map[string]updateOp{},
+		st:                 sessionToken,
+		shouldUseMasterKey: useMasterKey,
+		tracked:            map[string]bool{},
+	}
+	for k, fv := range fields {
+		u.values[k] = updateOp{UpdateType: opSet, Value: fv}
+		u.tracked[k] = true
+	}
+	return u.Execute()
+}
+
+// Retrieve the first result of the query q into v, or create v if there are no
+// results. Returns whether v was created. v must point to a value of the type
+// the query was created with, and should be populated with the fields to
+// create it with.
+//
+// The create request is made with the same master key or session token as q.
+// If it fails because another object with the same value for a unique field was
+// created concurrently (error code 137), the query is made again.
+//
+// E.g.:
+//
+//	tag := Tag{Name: "golang"}
+//	q, _ := cli.NewQuery(&Tag{})
+//	q.EqualTo("name", tag.Name)
+//	created, err := cli.FindOrCreate(q, &tag)
+func (c *Client) FindOrCreate(q Query, v interface{}) (created bool, err error) {
+	qt, ok := q.(*query)
+	if !ok {
+		return false, fmt.Errorf("parse: unsupported query type %T", q)
+	} else if reflect.TypeOf(v) != reflect.TypeOf(qt.inst) {
+		return false, fmt.Errorf("parse: expected %v got %v", reflect.TypeOf(qt.inst), reflect.TypeOf(v))
+	} else if k := reflect.Indirect(reflect.ValueOf(v)).Kind(); k != reflect.Struct {
+		return false, fmt.Errorf("parse: expected a pointer to a struct got %v", k)
+	}
+
+	find := func() error {
+		fq := qt.Clone().(*query)
+		fq.inst = v
+		return fq.First()
+	}
+
+	if err := find(); err != ErrNoRows {
+		return false, err
+	}
+
+	err = c.create(v, qt.shouldUseMasterKey, qt.sessionToken())
+	if apiErr, ok := err.(APIError); ok && apiErr.Code() == duplicateValue {
+		// The cache may hold the empty result of the first lookup
+		c.invalidateCache(v)
+		if ferr := find(); ferr != ErrNoRows {
+			return false, ferr
+		}
+	}
+	return err == nil, err
+}
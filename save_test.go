@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type saveTestType struct {
+	Base
+	Name  string
+	Count int `parse:"count,omitempty"`
+}
+
+func (s *saveTestType) ClassName() string {
+	return "Tag"
+}
+
+func TestSave(t *testing.T) {
+	var method, path string
+	var body map[string]interface{}
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = map[string]interface{}{}
+		json.Unmarshal(b, &body)
+		if r.Method == "POST" {
+			fmt.Fprint(w, `{"objectId":"abc","createdAt":"2015-01-01T00:00:00.000Z"}`)
+		} else {
+			fmt.Fprint(w, `{"updatedAt":"2015-01-02T00:00:00.000Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	v := saveTestType{Name: "golang"}
+	if err := testClient.Save(&v, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != "POST" || path != "/1/classes/Tag" || v.Id != "abc" {
+		t.Errorf("expected object to be created. got %s %s (id=%s)", method, path, v.Id)
+	}
+
+	v.Name = "go"
+	if err := testClient.Save(&v, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != "PUT" || path != "/1/classes/Tag/abc" {
+		t.Errorf("expected object to be updated. got %s %s", method, path)
+	}
+	if len(body) != 1 || body["name"] != "go" {
+		t.Errorf("expected all fields to be sent. got %v", body)
+	}
+	if v.UpdatedAt.IsZero() {
+		t.Errorf("expected UpdatedAt to be set")
+	}
+}
+
+func TestFindOrCreate(t *testing.T) {
+	cases := []struct {
+		responses []string
+		created   bool
+		err       bool
+		requests  int
+	}{
+		// Found
+		{[]string{`{"results":[{"objectId":"abc","name":"golang","count":3}]}`}, false, false, 1},
+		// Not found
+		{[]string{`{"results":[]}`, `{"objectId":"abc"}`}, true, false, 2},
+		// Created concurrently
+		{[]string{`{"results":[]}`, `!{"code":137,"error":"duplicate value"}`, `{"results":[{"objectId":"abc","name":"golang","count":3}]}`}, false, false, 3},
+		// Duplicate of another unique field
+		{[]string{`{"results":[]}`, `!{"code":137,"error":"duplicate value"}`, `{"results":[]}`}, false, true, 3},
+	}
+
+	for i, tc := range cases {
+		requests := 0
+		setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+			resp := tc.responses[requests]
+			requests++
+			if resp[0] == '!' {
+				w.WriteHeader(http.StatusBadRequest)
+				resp = resp[1:]
+			}
+			fmt.Fprint(w, resp)
+		})
+
+		v := saveTestType{Name: "golang"}
+		q, _ := testClient.NewQuery(&saveTestType{})
+		q.EqualTo("name", "golang")
+		created, err := testClient.FindOrCreate(q, &v)
+
+		if created != tc.created {
+			t.Errorf("case %d: wrong created value. got %v expected %v", i, created, tc.created)
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+		if requests != tc.requests {
+			t.Errorf("case %d: wrong number of requests. got %d expected %d", i, requests, tc.requests)
+		}
+		if !tc.err && (v.Id != "abc" || v.Name != "golang") {
+			t.Errorf("case %d: unexpected value: %+v", i, v)
+		}
+		teardownTestServer()
+	}
+}
@@ -4,31 +4,126 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
 	"reflect"
+	"sync"
 )
 
+// Parse error code returned when a session token is invalid or has expired
+const invalidSessionToken = 209
+
+// Returned by Session methods once the session has been logged out
+var ErrSessionLoggedOut = errors.New("parse: session has been logged out")
+
 type Session interface {
 	User() interface{}
 	NewQuery(v interface{}) (Query, error)
 	NewUpdate(v interface{}) (Update, error)
 	Create(v interface{}) error
+	Save(v interface{}) error
 	Delete(v interface{}) error
 	CallFunction(name string, params Params, resp interface{}) error
+
+	// Log out of this session, revoking its session token. Once logged out,
+	// all other methods of the session return ErrSessionLoggedOut.
+	Logout() error
+
+	// Retrieve the _Session object for this session
+	GetCurrentSession() (*SessionObject, error)
+
+	// Exchange this session's legacy session token for a revocable one. The
+	// session uses the new token for all subsequent requests.
+	UpgradeToRevocableSession() (*SessionObject, error)
+
+	// Convert the anonymous user this session belongs to into a regular user
+	// with the given username, password and (optional) email address. The
+	// user keeps its Id and all of its data, and the session remains valid.
+	UpgradeAnonymous(username, password, email string) error
+
+	// Generate a new TOTP secret for enrolling this session's user in
+	// multi-factor authentication. issuer names the app in authenticator
+	// apps. The user is not enrolled until EnableMFA is called.
+	EnrollMFA(issuer string) (*MFAEnrollment, error)
+
+	// Enable multi-factor authentication for this session's user, using the
+	// secret from e. code is the current TOTP code generated from the secret,
+	// which confirms that it was added to an authenticator app. Returns
+	// recovery codes which may be used in place of a TOTP code.
+	EnableMFA(e *MFAEnrollment, code string) (recoveryCodes []string, err error)
+
+	// Save this session's token to store under key, and keep it up to date
+	// as the token changes. The token is removed from the store on Logout.
+	// Use Client.RestoreSession to restore the session.
+	Persist(store SessionStore, key string) error
+
+	// Set a function to be called when a request made with this session
+	// fails because its session token is invalid or has expired (error
+	// 209). f may re-authenticate, e.g. by calling Client.Login, and return
+	// the new session, whose token this session then uses. The request is
+	// retried once with the new token. If f returns an error, it is returned
+	// in place of the original error.
+	//
+	// f is called at most once for concurrent requests that fail with the
+	// same token. It must not make requests with this session.
+	OnInvalidSession(f func(s Session) (Session, error))
+
+	// As Client.NewTrackedUpdate, with the update made as this session's user
+	NewTrackedUpdate(v interface{}) (Update, error)
+
+	// As Client.FindOrCreate. Both the query and create request are made as
+	// this session's user, unless q uses the Master Key.
+	FindOrCreate(q Query, v interface{}) (created bool, err error)
+
+	// As Client.NewPushQuery, with the query made as this session's user
+	NewPushQuery() (Query, error)
+
+	// As Client.NewPushNotification, with the push sent as this session's
+	// user. Client push must be enabled for the app.
+	NewPushNotification() (PushNotification, error)
+
+	// As Client.GetConfig, with the request made as this session's user
+	GetConfig() (Config, error)
+
+	// Link this session's user to a third-party authentication provider.
+	// See Client.LinkWith.
+	LinkWith(provider string, authData map[string]interface{}) error
+
+	// Remove the link between this session's user and a third-party
+	// authentication provider
+	UnlinkWith(provider string) error
+
+	// Set headers to send with every request made with this session, in
+	// addition to those set for the client, replacing any set previously
+	// with this method. Queries, updates and push notifications already
+	// created from the session keep the headers in effect when they were
+	// created.
+	SetHeaders(h RequestHeaders)
 }
 
 type loginRequest struct {
 	username string
 	password string
 	s        *session
+
+	// Auth data to log in or sign up with. If username is also set, this is
+	// additional auth data for logging in with a password, e.g. for MFA
 	authdata *AuthData
 }
 
 type session struct {
 	client *Client
 
-	user         interface{}
+	user interface{}
+
+	mu           sync.RWMutex
 	sessionToken string
+	headers      RequestHeaders
+	loggedOut    bool
+	store        SessionStore
+	storeKey     string
+	onInvalid    func(s Session) (Session, error)
+
+	// Held while re-authenticating
+	reauthMu sync.Mutex
 }
 
 // Login in as the user identified by the provided username and password.
@@ -36,6 +131,9 @@ type session struct {
 // Optionally provide a custom User type to use in place of parse.User. If u is not
 // nil, it will be populated with the user's attributes, and will be accessible
 // by calling session.User().
+//
+// Returns ErrMFARequired if the user has multi-factor authentication enabled,
+// in which case LoginMFA must be used instead.
 func (c *Client) Login(username, password string, u interface{}) (Session, error) {
 	var user interface{}
 	if u == nil {
@@ -48,8 +146,8 @@ func (c *Client) Login(username, password string, u interface{}) (Session, error
 
 	s := &session{user: user, client: c}
 	if b, err := c.doRequest(&loginRequest{username: username, password: password}); err != nil {
-		return nil, err
-	} else if st, err := handleLoginResponse(b, s.user); err != nil {
+		return nil, mfaError(err)
+	} else if st, err := c.handleLoginResponse(b, s.user); err != nil {
 		return nil, err
 	} else {
 		s.sessionToken = st
@@ -58,26 +156,7 @@ func (c *Client) Login(username, password string, u interface{}) (Session, error
 }
 
 func (c *Client) LoginFacebook(authData *FacebookAuthData, u interface{}) (Session, error) {
-	var user interface{}
-
-	if u == nil {
-		user = &User{}
-	} else if err := validateUser(u); err != nil {
-		return nil, err
-	} else {
-		user = u
-	}
-
-	s := &session{user: user, client: c}
-	if b, err := c.doRequest(&loginRequest{authdata: &AuthData{Facebook: authData}}); err != nil {
-		return nil, err
-	} else if st, err := handleLoginResponse(b, s.user); err != nil {
-		return nil, err
-	} else {
-		s.sessionToken = st
-	}
-
-	return s, nil
+	return c.loginWithAuthData(&AuthData{Facebook: authData}, u)
 }
 
 // Log in as the user identified by the session token st
@@ -105,7 +184,7 @@ func (c *Client) Become(st string, u interface{}) (Session, error) {
 
 	if b, err := c.doRequest(r); err != nil {
 		return nil, err
-	} else if err := handleResponse(b, r.s.user); err != nil {
+	} else if err := c.handleResponse(b, r.s.user); err != nil {
 		return nil, err
 	}
 	return r.s, nil
@@ -115,74 +194,422 @@ func (s *session) User() interface{} {
 	return s.user
 }
 
+// Returns the session token, or ErrSessionLoggedOut if the session has been
+// logged out
+func (s *session) token() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.loggedOut {
+		return "", ErrSessionLoggedOut
+	}
+	return s.sessionToken, nil
+}
+
+// Returns the token to send with requests bound to this session. This is empty
+// once the session has logged out, so that such requests never fall back to a
+// token that has been revoked.
+func (s *session) currentToken() string {
+	st, _ := s.token()
+	return st
+}
+
 func (s *session) NewQuery(v interface{}) (Query, error) {
-	q, err := s.client.NewQuery(v)
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+	q, err := s.cli().NewQuery(v)
 	if err == nil {
 		if qt, ok := q.(*query); ok {
-			qt.st = s.sessionToken
+			qt.sess = s
 		}
 	}
 	return q, err
 }
 
 func (s *session) NewUpdate(v interface{}) (Update, error) {
-	u, err := s.client.NewUpdate(v)
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+	u, err := s.cli().NewUpdate(v)
+	if err == nil {
+		if ut, ok := u.(*updateRequest); ok {
+			ut.sess = s
+		}
+	}
+	return u, err
+}
+
+func (s *session) SetHeaders(h RequestHeaders) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = h
+}
+
+// Returns the client to make requests with, which sends the headers set with
+// SetHeaders
+func (s *session) cli() *Client {
+	s.mu.RLock()
+	h := s.headers
+	s.mu.RUnlock()
+	if h == (RequestHeaders{}) {
+		return s.client
+	}
+	return s.client.WithHeaders(h)
+}
+
+func (s *session) NewTrackedUpdate(v interface{}) (Update, error) {
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+	u, err := s.cli().NewTrackedUpdate(v)
 	if err == nil {
 		if ut, ok := u.(*updateRequest); ok {
-			ut.st = s.sessionToken
+			ut.sess = s
 		}
 	}
 	return u, err
 }
 
+func (s *session) FindOrCreate(q Query, v interface{}) (bool, error) {
+	if _, err := s.token(); err != nil {
+		return false, err
+	}
+	qt, ok := q.(*query)
+	if !ok {
+		return false, fmt.Errorf("parse: unsupported query type %T", q)
+	}
+
+	sq := qt.Clone().(*query)
+	if !sq.shouldUseMasterKey {
+		sq.sess = s
+	}
+	return s.cli().FindOrCreate(sq, v)
+}
+
+func (s *session) NewPushQuery() (Query, error) {
+	return s.NewQuery(&Installation{})
+}
+
+func (s *session) NewPushNotification() (PushNotification, error) {
+	if _, err := s.token(); err != nil {
+		return nil, err
+	}
+	return &pushRequest{client: s.cli(), sess: s}, nil
+}
+
+func (s *session) GetConfig() (Config, error) {
+	var cfg Config
+	err := s.do("config", func(st string) (err error) {
+		cfg, err = s.cli().getConfig(st)
+		return err
+	})
+	return cfg, err
+}
+
+func (s *session) LinkWith(provider string, authData map[string]interface{}) error {
+	return s.cli().setAuthData(s.user, provider, authData, s)
+}
+
+func (s *session) UnlinkWith(provider string) error {
+	return s.cli().setAuthData(s.user, provider, nil, s)
+}
+
 func (s *session) Create(v interface{}) error {
-	return s.client.create(v, false, s.sessionToken)
+	return s.do("create", func(st string) error {
+		return s.cli().create(v, false, st)
+	})
+}
+
+func (s *session) Save(v interface{}) error {
+	return s.do("save", func(st string) error {
+		return s.cli().save(v, false, st)
+	})
 }
 
 func (s *session) Delete(v interface{}) error {
-	return s.client._delete(v, false, s.sessionToken)
+	return s.do("delete", func(st string) error {
+		return s.cli()._delete(v, false, st)
+	})
 }
 
 func (s *session) CallFunction(name string, params Params, resp interface{}) error {
-	return s.client.callFn(name, params, resp, s.sessionToken)
+	return s.do("function", func(st string) error {
+		return s.cli().callFn(name, params, resp, st)
+	})
 }
 
-func (l *loginRequest) method() string {
-	if l.authdata != nil {
-		return "POST"
+func (s *session) Logout() error {
+	st, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	err = s.cli().RevokeSession(st)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code() == invalidSessionToken {
+		// The session has already expired or been revoked
+		err = nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return "GET"
+	s.mu.Lock()
+	s.sessionToken = ""
+	s.loggedOut = true
+	store, key := s.store, s.storeKey
+	s.mu.Unlock()
+
+	if store != nil {
+		return store.Delete(key)
+	}
+	return nil
 }
 
-func (l *loginRequest) endpoint() (string, error) {
-	var p string
-	u := url.URL{}
+func (s *session) GetCurrentSession() (*SessionObject, error) {
+	var so *SessionObject
+	err := s.do("get", func(st string) (err error) {
+		so, err = s.cli().GetCurrentSession(st)
+		return err
+	})
+	return so, err
+}
+
+func (s *session) UpgradeToRevocableSession() (*SessionObject, error) {
+	st, err := s.token()
+	if err != nil {
+		return nil, err
+	}
+
+	so, err := s.cli().UpgradeToRevocableSession(st)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setToken(so.SessionToken); err != nil && err != ErrSessionLoggedOut {
+		return nil, err
+	}
+	return so, nil
+}
+
+func (s *session) UpgradeAnonymous(username, password, email string) error {
+	if username == "" {
+		return ErrUsernameMissing
+	}
+	if password == "" {
+		return ErrPasswordMissing
+	}
+	if f := reflect.ValueOf(s.user).Elem().FieldByName("Id"); !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return errors.New("parse: user Id field must not be empty")
+	}
+
+	u, err := s.NewUpdate(s.user)
+	if err != nil {
+		return err
+	}
+	u.Set("username", username)
+	u.Set("password", password)
+	if email != "" {
+		u.Set("email", email)
+	}
+	u.Set("authData", map[string]interface{}{"anonymous": nil})
+
+	err = u.Execute()
+	if apiErr, ok := err.(APIError); ok && (apiErr.Code() == usernameTaken || apiErr.Code() == emailTaken) {
+		return accountError(err)
+	}
+	return err
+}
+
+// Retrieve the _Session object for the session token st
+func (c *Client) GetCurrentSession(st string) (*SessionObject, error) {
+	if st == "" {
+		return nil, errors.New("parse: session token must not be empty")
+	}
+
+	so := &SessionObject{}
+	q, _ := c.NewQuery(so)
+	q.SetSessionToken(st)
+	if err := q.Get("me"); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// Retrieve all sessions belonging to the user identified by userId. This
+// requires the Master Key.
+func (c *Client) ListSessions(userId string) ([]SessionObject, error) {
+	if userId == "" {
+		return nil, errors.New("parse: user Id must not be empty")
+	}
+
+	const pageSize = 1000
+	all := []SessionObject{}
+	for {
+		page := []SessionObject{}
+		q, _ := c.NewQuery(&page)
+		q.UseMasterKey()
+		q.EqualTo("user", Pointer{Id: userId, ClassName: "_User"})
+		q.OrderBy("objectId")
+		q.Limit(pageSize)
+		q.Skip(len(all))
+		if err := q.Find(); err == ErrNoRows {
+			return all, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+// Exchange the legacy session token st for a revocable session. The returned
+// session's SessionToken should be used in place of st.
+func (c *Client) UpgradeToRevocableSession(st string) (*SessionObject, error) {
+	if st == "" {
+		return nil, errors.New("parse: session token must not be empty")
+	}
+
+	b, err := c.doRequest(&upgradeSessionRequest{st: st})
+	if err != nil {
+		return nil, err
+	}
+
+	so := &SessionObject{}
+	if err := c.handleResponse(b, so); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+// Revoke the session token st, logging out of the session it belongs to
+func (c *Client) RevokeSession(st string) error {
+	if st == "" {
+		return errors.New("parse: session token must not be empty")
+	}
+	_, err := c.doRequest(&logoutRequest{st: st})
+	return err
+}
+
+// Revoke all sessions belonging to the user identified by userId, logging the
+// user out everywhere. This requires the Master Key.
+func (c *Client) RevokeAllSessions(userId string) error {
+	if userId == "" {
+		return errors.New("parse: user Id must not be empty")
+	}
+
+	for {
+		sessions := []SessionObject{}
+		q, _ := c.NewQuery(&sessions)
+		q.UseMasterKey()
+		q.EqualTo("user", Pointer{Id: userId, ClassName: "_User"})
+		q.Keys("objectId")
+		q.Limit(1000)
+		if err := q.Find(); err == ErrNoRows {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		for i := range sessions {
+			if err := c.Delete(&sessions[i], true); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type logoutRequest struct {
+	st string
+}
 
+func (l *logoutRequest) method() string {
+	return "POST"
+}
+
+func (l *logoutRequest) endpoint() (string, error) {
+	return "logout", nil
+}
+
+func (l *logoutRequest) body() (string, error) {
+	return "{}", nil
+}
+
+func (l *logoutRequest) useMasterKey() bool {
+	return false
+}
+
+func (l *logoutRequest) sessionToken() string {
+	return l.st
+}
+
+func (l *logoutRequest) contentType() string {
+	return "application/json"
+}
+
+type upgradeSessionRequest struct {
+	st string
+}
+
+func (u *upgradeSessionRequest) method() string {
+	return "POST"
+}
+
+func (u *upgradeSessionRequest) endpoint() (string, error) {
+	return "upgradeToRevocableSession", nil
+}
+
+func (u *upgradeSessionRequest) body() (string, error) {
+	return "{}", nil
+}
+
+func (u *upgradeSessionRequest) useMasterKey() bool {
+	return false
+}
+
+func (u *upgradeSessionRequest) sessionToken() string {
+	return u.st
+}
+
+func (u *upgradeSessionRequest) contentType() string {
+	return "application/json"
+}
+
+func (l *loginRequest) method() string {
 	if l.s != nil {
-		p = "users/me"
-	} else if l.authdata != nil {
-		p = "users"
-	} else {
-		p = "login"
+		return "GET"
 	}
-	u.Path = p
+	return "POST"
+}
 
-	if l.username != "" && l.password != "" {
-		v := url.Values{}
-		v["username"] = []string{l.username}
-		v["password"] = []string{l.password}
-		u.RawQuery = v.Encode()
+func (l *loginRequest) endpoint() (string, error) {
+	if l.s != nil {
+		return "users/me", nil
+	} else if l.authdata != nil && l.username == "" {
+		return "users", nil
 	}
-	return u.String(), nil
+	return "login", nil
 }
 
+// Credentials are sent in the body rather than the URL, so that they do not
+// appear in proxy or access logs
 func (l *loginRequest) body() (string, error) {
+	if l.s != nil {
+		return "", nil
+	}
+
+	body := map[string]interface{}{}
+	if l.username != "" {
+		body["username"] = l.username
+		body["password"] = l.password
+	}
 	if l.authdata != nil {
-		b, err := json.Marshal(map[string]interface{}{"authData": l.authdata})
-		return string(b), err
+		body["authData"] = l.authdata
 	}
-	return "", nil
+	b, err := json.Marshal(body)
+	return string(b), err
 }
 
 func (l *loginRequest) useMasterKey() bool {
@@ -191,13 +618,35 @@ func (l *loginRequest) useMasterKey() bool {
 
 func (l *loginRequest) sessionToken() string {
 	if l.s != nil {
-		return l.s.sessionToken
+		st, _ := l.s.token()
+		return st
 	}
 	return ""
 }
 
 func (l *loginRequest) contentType() string {
-	return "application/x-www-form-urlencoded"
+	if l.s != nil {
+		return "application/x-www-form-urlencoded"
+	}
+	return "application/json"
+}
+
+// Returns whether op creates a new session, i.e. logs in or signs up
+func createsSession(op request) bool {
+	switch r := op.(type) {
+	case *loginRequest:
+		return r.s == nil
+	case *createRequest:
+		return r.isUser
+	}
+	return false
+}
+
+// Request revocable session tokens when logging in or signing up, by sending
+// the X-Parse-Revocable-Session header. This is only needed for apps that
+// still issue legacy session tokens. See also UpgradeToRevocableSession.
+func (c *Client) SetRevocableSessions(enabled bool) {
+	c.revocableSessions = enabled
 }
 
 func validateUser(u interface{}) error {
@@ -210,7 +659,7 @@ func validateUser(u interface{}) error {
 	return nil
 }
 
-func handleLoginResponse(body []byte, dst interface{}) (sessionToken string, err error) {
+func (c *Client) handleLoginResponse(body []byte, dst interface{}) (sessionToken string, err error) {
 	data := make(map[string]interface{})
 	if err := json.Unmarshal(body, &data); err != nil {
 		return "", err
@@ -220,5 +669,5 @@ func handleLoginResponse(body []byte, dst interface{}) (sessionToken string, err
 	if !ok {
 		return "", errors.New("parse: response did not contain sessionToken")
 	}
-	return st.(string), populateValue(dst, data)
+	return st.(string), c.populateValue(dst, data)
 }
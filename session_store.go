@@ -0,0 +1,321 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Returned by RestoreSession when there is no session stored under the given key
+var ErrSessionNotFound = errors.New("parse: no session found in store")
+
+// An interface for persisting session tokens, so that sessions may be restored
+// after a process restarts. Implementations must be safe for concurrent use.
+//
+// Load returns the session token stored under key, or an empty string if there
+// is none.
+//
+// Save stores sessionToken under key, replacing any existing token.
+//
+// Delete removes the token stored under key, if any.
+type SessionStore interface {
+	Load(key string) (sessionToken string, err error)
+	Save(key, sessionToken string) error
+	Delete(key string) error
+}
+
+type memorySessionStore struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// Create a SessionStore that keeps session tokens in memory
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{tokens: map[string]string{}}
+}
+
+func (m *memorySessionStore) Load(key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tokens[key], nil
+}
+
+func (m *memorySessionStore) Save(key, sessionToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[key] = sessionToken
+	return nil
+}
+
+func (m *memorySessionStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, key)
+	return nil
+}
+
+type fileSessionStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Create a SessionStore that keeps session tokens in the file at path, as a
+// JSON object keyed by session key. The file is created if it does not exist,
+// and is readable only by its owner.
+//
+// Stores for the same path should not be used by more than one process at a
+// time.
+func NewFileSessionStore(path string) SessionStore {
+	return &fileSessionStore{path: path}
+}
+
+func (f *fileSessionStore) read() (map[string]string, error) {
+	tokens := map[string]string{}
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return tokens, nil
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Replaces the file with tokens. The file is written in full before being
+// renamed into place, so that it is never left partially written.
+func (f *fileSessionStore) write(tokens map[string]string) error {
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *fileSessionStore) Load(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	return tokens[key], nil
+}
+
+func (f *fileSessionStore) Save(key, sessionToken string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+	tokens[key] = sessionToken
+	return f.write(tokens)
+}
+
+func (f *fileSessionStore) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[key]; !ok {
+		return nil
+	}
+	delete(tokens, key)
+	return f.write(tokens)
+}
+
+// Restore the session stored under key in store, and attach the store to it as
+// with Session.Persist. Returns ErrSessionNotFound if there is no such session.
+// If the stored session token is no longer valid, it is removed from the store
+// and the APIError is returned.
+//
+// Optionally provide a custom User type to use in place of parse.User. If u is not
+// nil, it will be populated with the user's attributes, and will be accessible
+// by calling session.User().
+func (c *Client) RestoreSession(store SessionStore, key string, u interface{}) (Session, error) {
+	st, err := store.Load(key)
+	if err != nil {
+		return nil, err
+	} else if st == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	s, err := c.Become(st, u)
+	if apiErr, ok := err.(APIError); ok && apiErr.Code() == invalidSessionToken {
+		if derr := store.Delete(key); derr != nil {
+			return nil, derr
+		}
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	ss := s.(*session)
+	ss.store, ss.storeKey = store, key
+	return ss, nil
+}
+
+func (s *session) Persist(store SessionStore, key string) error {
+	st, err := s.token()
+	if err != nil {
+		return err
+	}
+	if err := store.Save(key, st); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store, s.storeKey = store, key
+	return nil
+}
+
+func (s *session) OnInvalidSession(f func(s Session) (Session, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onInvalid = f
+}
+
+// Sets the session token to st, saving it to the session store if one is
+// attached
+func (s *session) setToken(st string) error {
+	s.mu.Lock()
+	if s.loggedOut {
+		s.mu.Unlock()
+		return ErrSessionLoggedOut
+	}
+	s.sessionToken = st
+	store, key := s.store, s.storeKey
+	s.mu.Unlock()
+
+	if store != nil {
+		return store.Save(key, st)
+	}
+	return nil
+}
+
+// Returns whether a request made with this session that failed with err may be
+// retried after re-authenticating
+func (s *session) canRetry(err error) bool {
+	if apiErr, ok := err.(APIError); !ok || apiErr.Code() != invalidSessionToken {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.onInvalid != nil && !s.loggedOut
+}
+
+// Replaces the session token failed, which was rejected by Parse, with one
+// from the session returned by the OnInvalidSession callback. If the token has
+// already been replaced, e.g. by a concurrent request, the current token is
+// returned without calling the callback again.
+func (s *session) reauthenticate(failed string) (string, error) {
+	s.reauthMu.Lock()
+	defer s.reauthMu.Unlock()
+
+	s.mu.RLock()
+	current, f := s.sessionToken, s.onInvalid
+	s.mu.RUnlock()
+	if current != failed {
+		return current, nil
+	}
+
+	ns, err := f(s)
+	if err != nil {
+		return "", err
+	}
+	nss, ok := ns.(*session)
+	if !ok || nss == nil {
+		return "", errors.New("parse: OnInvalidSession must return a session created by a Client")
+	}
+	st, err := nss.token()
+	if err != nil {
+		return "", err
+	}
+	return st, s.setToken(st)
+}
+
+// Calls f with the session token, calling it again with a new token if it fails
+// with an invalid session error and the session has an OnInvalidSession
+// callback. name is the name of the operation, used for metrics.
+func (s *session) do(name string, f func(st string) error) error {
+	st, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	err = f(st)
+	if !s.canRetry(err) {
+		return err
+	}
+	if st, err = s.reauthenticate(st); err != nil {
+		return err
+	}
+	s.client.observeRetry(name, retryInvalidSession)
+	return f(st)
+}
+
+// Implemented by requests made on behalf of a Session, which are retried after
+// re-authenticating if the session token is invalid
+type sessionBound interface {
+	boundSession() *session
+}
+
+// Executes op, retrying once if op is bound to a session whose token was
+// rejected and which can re-authenticate. Returns ErrSessionLoggedOut without
+// sending op if the session has been logged out.
+func (c *Client) doSessionRequest(op request, s *session) ([]byte, error) {
+	var b []byte
+	err := c.retrySession(op, s, func() (err error) {
+		b, err = c.doRequestOnce(op)
+		return err
+	})
+	return b, err
+}
+
+// Calls send, which sends op, calling it again after re-authenticating if op
+// was rejected because the token of s is invalid
+func (c *Client) retrySession(op request, s *session, send func() error) error {
+	st, err := s.token()
+	if err != nil {
+		return err
+	}
+	err = send()
+	if !s.canRetry(err) {
+		return err
+	}
+	if _, err := s.reauthenticate(st); err != nil {
+		return err
+	}
+	name, _ := describe(op)
+	c.observeRetry(name, retryInvalidSession)
+	return send()
+}
@@ -0,0 +1,254 @@
+package parse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	if st, err := store.Load("missing"); err != nil || st != "" {
+		t.Errorf("expected no token for a missing key. got [%v], err [%v]", st, err)
+	}
+
+	if err := store.Save("kyle", "abcd"); err != nil {
+		t.Errorf("unexpected error saving token: %v", err)
+	}
+	if err := store.Save("other", "efgh"); err != nil {
+		t.Errorf("unexpected error saving token: %v", err)
+	}
+	if st, err := store.Load("kyle"); err != nil || st != "abcd" {
+		t.Errorf("wrong token loaded. got [%v] expected [%v], err [%v]", st, "abcd", err)
+	}
+
+	if err := store.Delete("kyle"); err != nil {
+		t.Errorf("unexpected error deleting token: %v", err)
+	}
+	if st, err := store.Load("kyle"); err != nil || st != "" {
+		t.Errorf("expected no token after delete. got [%v], err [%v]", st, err)
+	}
+	if st, err := store.Load("other"); err != nil || st != "efgh" {
+		t.Errorf("wrong token loaded. got [%v] expected [%v], err [%v]", st, "efgh", err)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	testSessionStore(t, NewMemorySessionStore())
+}
+
+func TestFileSessionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parse-sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sessions.json")
+	testSessionStore(t, NewFileSessionStore(path))
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Errorf("unexpected error reading session file: %v", err)
+		t.FailNow()
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("wrong session file permissions. got [%v] expected [%v]", perm, os.FileMode(0600))
+	}
+
+	// A new store for the same file sees tokens saved by the first
+	if st, err := NewFileSessionStore(path).Load("other"); err != nil || st != "efgh" {
+		t.Errorf("wrong token loaded from file. got [%v] expected [%v], err [%v]", st, "efgh", err)
+	}
+}
+
+func TestRestoreSession(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/users/me" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get(SessionTokenHeader) == "abcd" {
+			fmt.Fprintf(w, `{"objectId":"user1","username":"kylemcc"}`)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	store := NewMemorySessionStore()
+	if _, err := testClient.RestoreSession(store, "kyle", nil); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound. got [%v]", err)
+	}
+
+	store.Save("kyle", "abcd")
+	s, err := testClient.RestoreSession(store, "kyle", nil)
+	if err != nil {
+		t.Errorf("unexpected error restoring session: %v", err)
+		t.FailNow()
+	}
+	if u := s.User().(*User); u.Id != "user1" || u.Username != "kylemcc" {
+		t.Errorf("restored session has wrong user. got [%+v]", u)
+	}
+
+	store.Save("stale", "expired")
+	if _, err := testClient.RestoreSession(store, "stale", nil); err == nil {
+		t.Errorf("expected an error restoring an expired session")
+	}
+	if st, _ := store.Load("stale"); st != "" {
+		t.Errorf("expired session was not removed from the store. got [%v]", st)
+	}
+}
+
+func TestPersistAndLogout(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{}`)
+	})
+	defer teardownTestServer()
+
+	store := NewMemorySessionStore()
+	s := &session{client: testClient, user: &User{}, sessionToken: "abcd"}
+	if err := s.Persist(store, "kyle"); err != nil {
+		t.Errorf("unexpected error persisting session: %v", err)
+	}
+	if st, _ := store.Load("kyle"); st != "abcd" {
+		t.Errorf("session was not persisted. got [%v] expected [%v]", st, "abcd")
+	}
+
+	if err := s.Logout(); err != nil {
+		t.Errorf("unexpected error on logout: %v", err)
+	}
+	if st, _ := store.Load("kyle"); st != "" {
+		t.Errorf("session was not removed from the store on logout. got [%v]", st)
+	}
+}
+
+// Returns a handler which rejects requests without the session token "new"
+func reauthTestHandler(requests *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get(SessionTokenHeader) != "new" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+			return
+		}
+		if r.Method == "GET" {
+			fmt.Fprintf(w, `{"results":[{"objectId":"abc"}]}`)
+		} else {
+			fmt.Fprintf(w, `{"objectId":"abc","createdAt":"2014-04-01T14:44:14.123Z"}`)
+		}
+	}
+}
+
+func TestOnInvalidSessionRetries(t *testing.T) {
+	var requests int32
+	setupTestServer(reauthTestHandler(&requests))
+	defer teardownTestServer()
+
+	m := &testMetrics{}
+	cli := *testClient
+	cli.SetInstrumentation(nil, m)
+
+	store := NewMemorySessionStore()
+	reauths := 0
+	newSession := func() *session {
+		s := &session{client: &cli, user: &User{}, sessionToken: "old"}
+		s.Persist(store, "kyle")
+		s.OnInvalidSession(func(old Session) (Session, error) {
+			reauths++
+			if old != s {
+				t.Errorf("callback was not passed the session")
+			}
+			return &session{client: &cli, user: &User{}, sessionToken: "new"}, nil
+		})
+		return s
+	}
+
+	s := newSession()
+	if err := s.Create(&User{}); err != nil {
+		t.Errorf("unexpected error on create: %v", err)
+	}
+	if requests != 2 || reauths != 1 {
+		t.Errorf("expected 2 requests and 1 re-authentication. got [%d] and [%d]", requests, reauths)
+	}
+	if st, _ := store.Load("kyle"); st != "new" {
+		t.Errorf("store was not updated with the new token. got [%v]", st)
+	}
+
+	s = newSession()
+	q, _ := s.NewQuery(&User{})
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error on query: %v", err)
+	}
+	if requests != 4 || reauths != 2 {
+		t.Errorf("expected 4 requests and 2 re-authentications. got [%d] and [%d]", requests, reauths)
+	}
+
+	// Requests made after re-authenticating use the new token
+	if err := q.First(); err != nil {
+		t.Errorf("unexpected error on query: %v", err)
+	}
+	if requests != 5 || reauths != 2 {
+		t.Errorf("expected 5 requests and 2 re-authentications. got [%d] and [%d]", requests, reauths)
+	}
+
+	expected := []string{"create:invalid_session", "find:invalid_session"}
+	if !reflect.DeepEqual(m.retries, expected) {
+		t.Errorf("wrong retries observed. got [%v] expected [%v]", m.retries, expected)
+	}
+}
+
+func TestOnInvalidSessionConcurrent(t *testing.T) {
+	var requests int32
+	setupTestServer(reauthTestHandler(&requests))
+	defer teardownTestServer()
+
+	var reauths int32
+	s := &session{client: testClient, user: &User{}, sessionToken: "old"}
+	s.OnInvalidSession(func(Session) (Session, error) {
+		atomic.AddInt32(&reauths, 1)
+		return &session{client: testClient, user: &User{}, sessionToken: "new"}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q, _ := s.NewQuery(&User{})
+			if err := q.First(); err != nil {
+				t.Errorf("unexpected error on query: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reauths != 1 {
+		t.Errorf("expected concurrent requests to re-authenticate once. got [%d]", reauths)
+	}
+}
+
+func TestOnInvalidSessionError(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "old"}
+	if err := s.Delete(&User{}); err == nil {
+		t.Errorf("expected an invalid session error without a callback")
+	}
+
+	s.OnInvalidSession(func(Session) (Session, error) {
+		return nil, ErrMFARequired
+	})
+	if err := s.Delete(&User{}); err != ErrMFARequired {
+		t.Errorf("expected the callback's error. got [%v]", err)
+	}
+}
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -10,13 +11,20 @@ import (
 
 func TestLogin(t *testing.T) {
 	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
-		r.ParseForm()
-		if r.Form.Get("username") != "username" {
-			t.Errorf("login request did not include proper username. got [%v] expected [%v]\n", r.Form.Get("username"), "username")
+		if r.Method != "POST" || r.URL.Path != "/1/login" || r.URL.RawQuery != "" {
+			t.Errorf("unexpected login request: %s %s", r.Method, r.URL)
 		}
 
-		if r.Form.Get("password") != "password" {
-			t.Errorf("login request did not include proper password. got [%v] expected [%v]\n", r.Form.Get("password"), "password")
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error decoding login request body: %v\n", err)
+		}
+		if body["username"] != "username" {
+			t.Errorf("login request did not include proper username. got [%v] expected [%v]\n", body["username"], "username")
+		}
+
+		if body["password"] != "password" {
+			t.Errorf("login request did not include proper password. got [%v] expected [%v]\n", body["password"], "password")
 		}
 
 		fmt.Fprintf(w, `{"sessionToken":"abcd","username":"kylemcc@gmail.com","createdAt":"2014-04-01T14:44:14.123Z","updatedAt":"2014-12-01T12:34:56.789Z"}`)
@@ -59,13 +67,20 @@ type CustomUser struct {
 
 func TestLoginCustomUserType(t *testing.T) {
 	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
-		r.ParseForm()
-		if r.Form.Get("username") != "username" {
-			t.Errorf("login request did not include proper username. got [%v] expected [%v]\n", r.Form.Get("username"), "username")
+		if r.Method != "POST" || r.URL.Path != "/1/login" || r.URL.RawQuery != "" {
+			t.Errorf("unexpected login request: %s %s", r.Method, r.URL)
+		}
+
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("unexpected error decoding login request body: %v\n", err)
+		}
+		if body["username"] != "username" {
+			t.Errorf("login request did not include proper username. got [%v] expected [%v]\n", body["username"], "username")
 		}
 
-		if r.Form.Get("password") != "password" {
-			t.Errorf("login request did not include proper password. got [%v] expected [%v]\n", r.Form.Get("password"), "password")
+		if body["password"] != "password" {
+			t.Errorf("login request did not include proper password. got [%v] expected [%v]\n", body["password"], "password")
 		}
 
 		fmt.Fprintf(w, `{"sessionToken":"abcd","username":"kylemcc@gmail.com","createdAt":"2014-04-01T14:44:14.123Z","updatedAt":"2014-12-01T12:34:56.789Z","phone":"3105551234","city":"Santa Monica"}`)
@@ -173,3 +188,365 @@ func TestSessionOperationsSetSessionTokenHeader(t *testing.T) {
 		t.Errorf("unexpected error executing query: %v\n", err)
 	}
 }
+
+func TestSessionLogout(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != "POST" || r.URL.Path != "/1/logout" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "session_token" {
+			t.Errorf("logout request had wrong session token. got [%v] expected [%v]", h, "session_token")
+		}
+		fmt.Fprintf(w, `{}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "session_token"}
+	if err := s.Logout(); err != nil {
+		t.Errorf("unexpected error on logout: %v", err)
+	}
+	if s.sessionToken != "" {
+		t.Errorf("logout did not clear the session token. got [%v]", s.sessionToken)
+	}
+
+	if err := s.Create(&User{}); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from Create. got [%v]", err)
+	}
+	if _, err := s.NewQuery(&User{}); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from NewQuery. got [%v]", err)
+	}
+	if err := s.Logout(); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from second Logout. got [%v]", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request. got [%d]", requests)
+	}
+}
+
+func TestSessionLogoutBoundRequests(t *testing.T) {
+	requests := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/1/logout" {
+			t.Errorf("unexpected request after logout: %s %s (session token [%s])", r.Method, r.URL.Path, r.Header.Get(SessionTokenHeader))
+		}
+		fmt.Fprintf(w, `{}`)
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetCache(NewLRUCache(10), time.Minute)
+	s := &session{client: &cli, user: &User{}, sessionToken: "session_token"}
+
+	q, _ := s.NewQuery(&User{})
+	u, _ := s.NewUpdate(&User{Base: Base{Id: "abc"}})
+	u.Set("username", "kylemcc")
+	p, _ := s.NewPushNotification()
+
+	if err := s.Logout(); err != nil {
+		t.Fatalf("unexpected error on logout: %v", err)
+	}
+
+	if err := q.Get("abc"); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from query. got [%v]", err)
+	}
+	if err := q.Find(); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from query. got [%v]", err)
+	}
+	if err := u.Execute(); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from update. got [%v]", err)
+	}
+	if err := p.Send(); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from push. got [%v]", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected no requests after logout. got [%d] requests", requests-1)
+	}
+}
+
+func TestSessionLogoutInvalidToken(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":209,"error":"invalid session token"}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "expired"}
+	if err := s.Logout(); err != nil {
+		t.Errorf("unexpected error logging out of an expired session: %v", err)
+	}
+	if err := s.Delete(&User{}); err != ErrSessionLoggedOut {
+		t.Errorf("expected ErrSessionLoggedOut from Delete. got [%v]", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	deleted := []string{}
+	finds := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get(MasterKeyHeader); h != "master_key" {
+			t.Errorf("request did not have Master Key header set!")
+		}
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/1/sessions":
+			finds++
+			where := r.URL.Query().Get("where")
+			if expected := `{"user":{"__type":"Pointer","className":"_User","objectId":"user1"}}`; where != expected {
+				t.Errorf("wrong where clause. got [%v] expected [%v]", where, expected)
+			}
+			if finds == 1 {
+				fmt.Fprintf(w, `{"results":[{"objectId":"s1"},{"objectId":"s2"}]}`)
+			} else {
+				fmt.Fprintf(w, `{"results":[]}`)
+			}
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			fmt.Fprintf(w, `{}`)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	})
+	defer teardownTestServer()
+
+	if err := testClient.RevokeAllSessions("user1"); err != nil {
+		t.Errorf("unexpected error revoking sessions: %v", err)
+	}
+
+	expected := []string{"/1/sessions/s1", "/1/sessions/s2"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("wrong sessions deleted. got [%v] expected [%v]", deleted, expected)
+	}
+	if finds != 2 {
+		t.Errorf("expected 2 queries. got [%d]", finds)
+	}
+}
+
+func TestGetCurrentSession(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/1/sessions/me" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "session_token" {
+			t.Errorf("request had wrong session token. got [%v] expected [%v]", h, "session_token")
+		}
+		fmt.Fprintf(w, `{"objectId":"s1","sessionToken":"session_token","user":{"__type":"Pointer","className":"_User","objectId":"user1"},"createdWith":{"action":"login","authProvider":"password"},"installationId":"inst1","expiresAt":{"__type":"Date","iso":"2015-04-01T14:44:14.123Z"},"restricted":false,"createdAt":"2014-04-01T14:44:14.123Z","updatedAt":"2014-04-01T14:44:14.123Z"}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "session_token"}
+	so, err := s.GetCurrentSession()
+	if err != nil {
+		t.Errorf("unexpected error getting current session: %v", err)
+		t.FailNow()
+	}
+
+	expiresAt := time.Date(2015, 4, 1, 14, 44, 14, 123000000, time.UTC)
+	createdWith := map[string]interface{}{"action": "login", "authProvider": "password"}
+	if so.Id != "s1" {
+		t.Errorf("wrong session Id. got [%v] expected [%v]", so.Id, "s1")
+	}
+	if so.SessionToken != "session_token" {
+		t.Errorf("wrong session token. got [%v] expected [%v]", so.SessionToken, "session_token")
+	}
+	if so.User == nil || so.User.Id != "user1" {
+		t.Errorf("wrong session user. got [%+v] expected Id [%v]", so.User, "user1")
+	}
+	if !reflect.DeepEqual(so.CreatedWith, createdWith) {
+		t.Errorf("wrong createdWith. got [%v] expected [%v]", so.CreatedWith, createdWith)
+	}
+	if so.InstallationId != "inst1" {
+		t.Errorf("wrong installation Id. got [%v] expected [%v]", so.InstallationId, "inst1")
+	}
+	if !so.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("wrong expiry. got [%v] expected [%v]", so.ExpiresAt, expiresAt)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/1/sessions" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "master_key" {
+			t.Errorf("request did not have Master Key header set!")
+		}
+		if where := r.URL.Query().Get("where"); where != `{"user":{"__type":"Pointer","className":"_User","objectId":"user1"}}` {
+			t.Errorf("wrong where clause. got [%v]", where)
+		}
+		fmt.Fprintf(w, `{"results":[{"objectId":"s1","installationId":"a"},{"objectId":"s2","installationId":"b"}]}`)
+	})
+	defer teardownTestServer()
+
+	sessions, err := testClient.ListSessions("user1")
+	if err != nil {
+		t.Errorf("unexpected error listing sessions: %v", err)
+		t.FailNow()
+	}
+	if len(sessions) != 2 || sessions[0].Id != "s1" || sessions[1].InstallationId != "b" {
+		t.Errorf("wrong sessions returned: %+v", sessions)
+	}
+}
+
+func TestUpgradeToRevocableSession(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/1/upgradeToRevocableSession" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(SessionTokenHeader); h != "legacy_token" {
+			t.Errorf("request had wrong session token. got [%v] expected [%v]", h, "legacy_token")
+		}
+		fmt.Fprintf(w, `{"objectId":"s1","sessionToken":"r:new_token","createdWith":{"action":"upgrade"}}`)
+	})
+	defer teardownTestServer()
+
+	s := &session{client: testClient, user: &User{}, sessionToken: "legacy_token"}
+	so, err := s.UpgradeToRevocableSession()
+	if err != nil {
+		t.Errorf("unexpected error upgrading session: %v", err)
+		t.FailNow()
+	}
+	if so.SessionToken != "r:new_token" {
+		t.Errorf("wrong session token returned. got [%v] expected [%v]", so.SessionToken, "r:new_token")
+	}
+	if s.sessionToken != "r:new_token" {
+		t.Errorf("session did not use the new token. got [%v] expected [%v]", s.sessionToken, "r:new_token")
+	}
+}
+
+func TestLoginHeaders(t *testing.T) {
+	var header http.Header
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprintf(w, `{"objectId":"user1","sessionToken":"r:abcd"}`)
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	if _, err := cli.Login("username", "password", nil); err != nil {
+		t.Errorf("unexpected error on login: %v", err)
+	}
+	if h := header.Get(RevocableSessionHeader); h != "" {
+		t.Errorf("revocable session header was set by default. got [%v]", h)
+	}
+	if h := header.Get(InstallationIdHeader); h != "" {
+		t.Errorf("installation Id header was set by default. got [%v]", h)
+	}
+
+	cli.SetRevocableSessions(true)
+	cli.SetInstallationId("inst1")
+	if _, err := cli.Login("username", "password", nil); err != nil {
+		t.Errorf("unexpected error on login: %v", err)
+	}
+	if h := header.Get(RevocableSessionHeader); h != "1" {
+		t.Errorf("wrong revocable session header. got [%v] expected [%v]", h, "1")
+	}
+	if h := header.Get(InstallationIdHeader); h != "inst1" {
+		t.Errorf("wrong installation Id header. got [%v] expected [%v]", h, "inst1")
+	}
+
+	if err := cli.Signup("username", "password", &User{}); err != nil {
+		t.Errorf("unexpected error on signup: %v", err)
+	}
+	if h := header.Get(RevocableSessionHeader); h != "1" {
+		t.Errorf("wrong revocable session header on signup. got [%v] expected [%v]", h, "1")
+	}
+
+	if _, err := cli.Become("r:abcd", nil); err != nil {
+		t.Errorf("unexpected error on become: %v", err)
+	}
+	if h := header.Get(RevocableSessionHeader); h != "" {
+		t.Errorf("revocable session header was set for an existing session. got [%v]", h)
+	}
+}
+
+func TestSessionClientParitySetSessionTokenHeader(t *testing.T) {
+	var requests []string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if h := r.Header.Get(SessionTokenHeader); h != "session_token" {
+			t.Errorf("%s %s did not have Session Token header set!", r.Method, r.URL.Path)
+		}
+		if h := r.Header.Get(MasterKeyHeader); h != "" {
+			t.Errorf("%s %s had Master Key header set!", r.Method, r.URL.Path)
+		}
+
+		switch {
+		case r.URL.Path == "/1/config":
+			fmt.Fprintf(w, `{"params":{"welcome":"hello"}}`)
+		case r.Method == "GET":
+			fmt.Fprintf(w, `{"results":[]}`)
+		case r.Method == "POST" && r.URL.Path != "/1/push":
+			fmt.Fprintf(w, `{"objectId":"abc","createdAt":"2014-04-01T14:44:14.123Z"}`)
+		default:
+			fmt.Fprintf(w, `{"updatedAt":"2014-04-01T14:44:14.123Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetTracker(NewTracker(0))
+	var s Session = &session{
+		client:       &cli,
+		user:         &User{Base: Base{Id: "user1"}},
+		sessionToken: "session_token",
+	}
+
+	if cfg, err := s.GetConfig(); err != nil || cfg.String("welcome") != "hello" {
+		t.Errorf("unexpected result from Session.GetConfig: %v, %v", cfg, err)
+	}
+
+	pq, err := s.NewPushQuery()
+	if err != nil {
+		t.Errorf("unexpected error on Session.NewPushQuery: %v", err)
+		t.FailNow()
+	}
+	pq.EqualTo("deviceType", "ios")
+	p, err := s.NewPushNotification()
+	if err != nil {
+		t.Errorf("unexpected error on Session.NewPushNotification: %v", err)
+		t.FailNow()
+	}
+	if err := p.Where(pq).Data(map[string]interface{}{"alert": "hello"}).Send(); err != nil {
+		t.Errorf("unexpected error sending push: %v", err)
+	}
+
+	q, _ := s.NewQuery(&User{})
+	q.EqualTo("username", "kylemcc")
+	u := &User{Username: "kylemcc"}
+	if created, err := s.FindOrCreate(q, u); err != nil || !created {
+		t.Errorf("unexpected result from Session.FindOrCreate: %v, %v", created, err)
+	}
+
+	u.Email = "kylemcc@gmail.com"
+	tu, err := s.NewTrackedUpdate(u)
+	if err != nil {
+		t.Errorf("unexpected error on Session.NewTrackedUpdate: %v", err)
+		t.FailNow()
+	}
+	if err := tu.Execute(); err != nil {
+		t.Errorf("unexpected error executing tracked update: %v", err)
+	}
+
+	if err := s.LinkWith("github", map[string]interface{}{"id": "h"}); err != nil {
+		t.Errorf("unexpected error on Session.LinkWith: %v", err)
+	}
+	if err := s.UnlinkWith("github"); err != nil {
+		t.Errorf("unexpected error on Session.UnlinkWith: %v", err)
+	}
+
+	expected := []string{
+		"GET /1/config",
+		"POST /1/push",
+		"GET /1/users",
+		"POST /1/users",
+		"PUT /1/users/abc",
+		"PUT /1/users/user1",
+		"PUT /1/users/user1",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("wrong requests made. got [%v] expected [%v]", requests, expected)
+	}
+}
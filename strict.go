@@ -0,0 +1,179 @@
+package parse
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Returned in strict decoding mode when a response does not match the type it
+// is decoded into. Every problem found in the response is listed, sorted by
+// path. Fields that could be decoded are still populated.
+type DecodeError struct {
+	// JSON paths of fields in the response with no corresponding struct field,
+	// e.g. "results[2].nickname"
+	UnknownFields []string
+
+	// Fields whose values could not be decoded into the corresponding struct
+	// field
+	TypeMismatches []TypeMismatch
+
+	// JSON paths of fields tagged as required that were absent or null in the
+	// response
+	MissingFields []string
+}
+
+// Describes a value in a response that could not be decoded
+type TypeMismatch struct {
+	// JSON path of the value, e.g. "results[0].location"
+	Path string
+
+	// Description of the problem
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	parts := make([]string, 0, 3)
+	if len(e.UnknownFields) > 0 {
+		parts = append(parts, "unknown fields: "+strings.Join(e.UnknownFields, ", "))
+	}
+	if len(e.TypeMismatches) > 0 {
+		ms := make([]string, 0, len(e.TypeMismatches))
+		for _, m := range e.TypeMismatches {
+			ms = append(ms, m.Path+" ("+m.Reason+")")
+		}
+		parts = append(parts, "type mismatches: "+strings.Join(ms, ", "))
+	}
+	if len(e.MissingFields) > 0 {
+		parts = append(parts, "missing required fields: "+strings.Join(e.MissingFields, ", "))
+	}
+	return "parse: error decoding response - " + strings.Join(parts, "; ")
+}
+
+// Enable or disable strict decoding of objects returned by queries made with
+// this client. Strict decoding may also be enabled for individual queries with
+// Query.StrictDecoding.
+//
+// In strict mode, a *DecodeError is returned if a response contains fields
+// with no corresponding struct field (even if the type has an Extra field),
+// values that can not be converted to the type of their field, or is missing
+// fields tagged as required, e.g.:
+//
+//	type Post struct {
+//		parse.Base
+//		Title string `parse:"title,required"`
+//	}
+//
+// Required fields are not checked for queries that select specific keys.
+func (c *Client) SetStrictDecoding(strict bool) {
+	c.strictDecoding = strict
+}
+
+// Sets the path of the value being populated to the field key of the current
+// value, returning the previous path. Paths are only tracked in strict mode.
+func (d *decoder) enter(key string) string {
+	prev := d.path
+	if d.strict {
+		d.path = joinPath(prev, key)
+	}
+	return prev
+}
+
+// Sets the path of the value being populated to element i of the array at
+// path p, returning the previous path. Paths are only tracked in strict mode.
+func (d *decoder) enterIndex(p string, i int) string {
+	prev := d.path
+	if d.strict {
+		d.path = p + "[" + strconv.Itoa(i) + "]"
+	}
+	return prev
+}
+
+func joinPath(p, key string) string {
+	if p == "" {
+		return key
+	}
+	return p + "." + key
+}
+
+func (d *decoder) problem() *DecodeError {
+	if d.problems == nil {
+		d.problems = &DecodeError{}
+	}
+	return d.problems
+}
+
+// Records err as a type mismatch at the current path. Returns false if not in
+// strict mode, in which case err should be returned instead.
+func (d *decoder) mismatch(err error) bool {
+	if !d.strict {
+		return false
+	}
+	if de, ok := err.(*DecodeError); ok {
+		// Problems found by a nested decoder, e.g. in an Unmarshaler
+		p := d.problem()
+		p.UnknownFields = append(p.UnknownFields, de.UnknownFields...)
+		p.TypeMismatches = append(p.TypeMismatches, de.TypeMismatches...)
+		p.MissingFields = append(p.MissingFields, de.MissingFields...)
+		return true
+	}
+	p := d.problem()
+	p.TypeMismatches = append(p.TypeMismatches, TypeMismatch{
+		Path:   d.path,
+		Reason: strings.TrimPrefix(err.Error(), "parse: "),
+	})
+	return true
+}
+
+// Records the field key of the current value as unknown
+func (d *decoder) unknown(key string) {
+	p := d.problem()
+	p.UnknownFields = append(p.UnknownFields, joinPath(d.path, key))
+}
+
+// Records any required fields of sc not in found as missing
+func (d *decoder) checkRequired(sc *structCodec, found map[*fieldCodec]bool) {
+	if d.partial {
+		return
+	}
+	for _, fc := range sc.fields {
+		if fc.required && !found[fc] {
+			p := d.problem()
+			p.MissingFields = append(p.MissingFields, joinPath(d.path, fc.wireName))
+		}
+	}
+}
+
+// Returns err, or the problems found in strict mode if there was no error
+func (d *decoder) finish(err error) error {
+	if err == nil && d.problems != nil {
+		d.problems.sort()
+		return d.problems
+	}
+	return err
+}
+
+// Sorts the problems by path, so that they don't depend on the order in which
+// the fields of the response were visited
+func (e *DecodeError) sort() {
+	sort.Strings(e.UnknownFields)
+	sort.SliceStable(e.TypeMismatches, func(i, j int) bool {
+		return e.TypeMismatches[i].Path < e.TypeMismatches[j].Path
+	})
+	sort.Strings(e.MissingFields)
+}
+
+// Returns whether the number sv was converted to cv without losing
+// information, i.e. it is not a float with a fractional part converted to an
+// integer or out of range of the integer type
+func convertsExactly(sv, cv reflect.Value) bool {
+	switch cv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if sv.Kind() == reflect.Float64 || sv.Kind() == reflect.Float32 {
+			return cv.Convert(sv.Type()).Float() == sv.Float()
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package parse
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type strictTestType struct {
+	Base
+	Title    string `parse:"title,required"`
+	Views    int
+	Location GeoPoint
+	Author   *User
+}
+
+func (s *strictTestType) ClassName() string {
+	return "Post"
+}
+
+func TestStrictDecoding(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[
+			{"objectId":"a","title":"one","views":1,"author":{"__type":"Pointer","className":"_User","objectId":"u1"}},
+			{"objectId":"b","views":1.5,"subtitle":"x","location":"nowhere"},
+			{"objectId":"c","title":"three","views":"three","author":{"__type":"Object","className":"_User","objectId":"u2","nickname":"n"}}
+		]}`)
+	})
+	defer teardownTestServer()
+
+	posts := []strictTestType{}
+	q, _ := testClient.NewQuery(&posts)
+	if err := q.Find(); err == nil {
+		t.Errorf("expected an error in lenient mode")
+	} else if _, ok := err.(*DecodeError); ok {
+		t.Errorf("expected a *DecodeError only in strict mode. got %v", err)
+	}
+
+	q.StrictDecoding()
+	err := q.Find()
+	de, ok := err.(*DecodeError)
+	if !ok {
+		t.Fatalf("expected a *DecodeError, got %v", err)
+	}
+
+	if expected := []string{"results[1].subtitle", "results[2].author.nickname"}; !reflect.DeepEqual(de.UnknownFields, expected) {
+		t.Errorf("wrong unknown fields. got %v expected %v", de.UnknownFields, expected)
+	}
+
+	paths := []string{}
+	for _, m := range de.TypeMismatches {
+		paths = append(paths, m.Path)
+	}
+	if expected := []string{"results[1].location", "results[1].views", "results[2].views"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("wrong type mismatches. got %v expected %v", de.TypeMismatches, expected)
+	}
+
+	if expected := []string{"results[1].title"}; !reflect.DeepEqual(de.MissingFields, expected) {
+		t.Errorf("wrong missing fields. got %v expected %v", de.MissingFields, expected)
+	}
+
+	if len(posts) != 3 || posts[0].Title != "one" || posts[2].Author == nil || posts[2].Author.Id != "u2" {
+		t.Errorf("expected valid fields to be populated. got %+v", posts)
+	}
+}
+
+func TestStrictDecodingClient(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"objectId":"a","views":2}`)
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetStrictDecoding(true)
+
+	p := strictTestType{}
+	q, _ := cli.NewQuery(&p)
+	err := q.Get("a")
+	if de, ok := err.(*DecodeError); !ok || !reflect.DeepEqual(de.MissingFields, []string{"title"}) {
+		t.Errorf("expected missing title to be reported. got %v", err)
+	}
+
+	q, _ = cli.NewQuery(&p)
+	q.Keys("views")
+	if err := q.Get("a"); err != nil {
+		t.Errorf("expected required fields not to be checked when selecting keys. got %v", err)
+	}
+
+	if err := cli.Create(&p, false); err != nil {
+		t.Errorf("expected strict decoding not to apply to create responses. got %v", err)
+	}
+}
+
+type strictOrderTestType struct {
+	Base
+	A int    `parse:"a,required"`
+	B int    `parse:"b,required"`
+	C int    `parse:"c,required"`
+	D string `parse:"d"`
+	E string `parse:"e"`
+	F string `parse:"f"`
+}
+
+func (s *strictOrderTestType) ClassName() string {
+	return "Order"
+}
+
+func TestStrictDecodingOrder(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"objectId":"a","z":1,"y":2,"x":3,"w":4,"f":true,"e":[],"d":{}}`)
+	})
+	defer teardownTestServer()
+
+	expected := "parse: error decoding response - unknown fields: w, x, y, z; " +
+		"type mismatches: d (%s), e (%s), f (%s); missing required fields: a, b, c"
+
+	// Fields are visited in map order, so repeat to make sure the order of
+	// the problems does not depend on it
+	for i := 0; i < 20; i++ {
+		o := strictOrderTestType{}
+		q, _ := testClient.NewQuery(&o)
+		q.StrictDecoding()
+		err := q.Get("a")
+		de, ok := err.(*DecodeError)
+		if !ok {
+			t.Fatalf("expected a *DecodeError, got %v", err)
+		}
+
+		if expected := []string{"w", "x", "y", "z"}; !reflect.DeepEqual(de.UnknownFields, expected) {
+			t.Fatalf("wrong unknown fields. got %v expected %v", de.UnknownFields, expected)
+		}
+		paths := []string{}
+		reasons := []interface{}{}
+		for _, m := range de.TypeMismatches {
+			paths = append(paths, m.Path)
+			reasons = append(reasons, m.Reason)
+		}
+		if expected := []string{"d", "e", "f"}; !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("wrong type mismatches. got %v expected %v", de.TypeMismatches, expected)
+		}
+		if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(de.MissingFields, expected) {
+			t.Fatalf("wrong missing fields. got %v expected %v", de.MissingFields, expected)
+		}
+		if msg := fmt.Sprintf(expected, reasons...); err.Error() != msg {
+			t.Fatalf("wrong error message. got [%s] expected [%s]", err.Error(), msg)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package parse
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Returned by Update.Execute when IfUnmodified was called and the object has
+// been modified since it was retrieved
+var ErrConcurrentModification = errors.New("parse: object has been modified since it was retrieved")
+
+// Records snapshots of objects so that only the fields which have changed
+// need to be sent when saving them.
+//
+// Objects are identified by class name and Id, so a snapshot taken of one
+// value may be used to save a copy of it. A Tracker is safe for concurrent
+// use.
+type Tracker struct {
+	mu        sync.Mutex
+	size      int
+	ll        *list.List
+	snapshots map[string]*list.Element
+}
+
+// The encoded value of each field of an object, by Parse field name. Fields
+// that would be omitted from a create request have no entry.
+type snapshot map[string][]byte
+
+type trackerEntry struct {
+	key      string
+	snapshot snapshot
+}
+
+// Create a new, empty Tracker holding snapshots of at most size objects. Once
+// full, the snapshot of the least recently used object is discarded to make
+// room for new ones. A size of 0 means the tracker is unbounded.
+func NewTracker(size int) *Tracker {
+	return &Tracker{
+		size:      size,
+		ll:        list.New(),
+		snapshots: map[string]*list.Element{},
+	}
+}
+
+// Returns the snapshot stored under key, marking it as recently used. Must be
+// called with t.mu held.
+func (t *Tracker) get(key string) (snapshot, bool) {
+	e, ok := t.snapshots[key]
+	if !ok {
+		return nil, false
+	}
+	t.ll.MoveToFront(e)
+	return e.Value.(*trackerEntry).snapshot, true
+}
+
+// Stores s under key, evicting the least recently used snapshot if the tracker
+// is full. Must be called with t.mu held.
+func (t *Tracker) put(key string, s snapshot) {
+	if e, ok := t.snapshots[key]; ok {
+		e.Value.(*trackerEntry).snapshot = s
+		t.ll.MoveToFront(e)
+		return
+	}
+
+	t.snapshots[key] = t.ll.PushFront(&trackerEntry{key: key, snapshot: s})
+	if t.size > 0 && t.ll.Len() > t.size {
+		oldest := t.ll.Back()
+		t.ll.Remove(oldest)
+		delete(t.snapshots, oldest.Value.(*trackerEntry).key)
+	}
+}
+
+// Take a snapshot of the object pointed to by v, replacing any previous
+// snapshot of the same object. v must have an Id.
+func (t *Tracker) Track(v interface{}) error {
+	key, s, err := takeSnapshot(v)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.put(key, s)
+	return nil
+}
+
+// Discard the snapshot of the object pointed to by v
+func (t *Tracker) Untrack(v interface{}) {
+	if key, err := trackingKey(v); err == nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if e, ok := t.snapshots[key]; ok {
+			t.ll.Remove(e)
+			delete(t.snapshots, key)
+		}
+	}
+}
+
+// Discard all snapshots
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ll.Init()
+	t.snapshots = map[string]*list.Element{}
+}
+
+// Returns the number of objects currently tracked
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ll.Len()
+}
+
+// Returns the names of the Parse fields of the object pointed to by v that have
+// changed since its snapshot was taken
+func (t *Tracker) Changed(v interface{}) ([]string, error) {
+	ops, err := t.diff(v)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		names = append(names, op.name)
+	}
+	return names, nil
+}
+
+// Track every object in v, which may be a pointer to a struct or to a slice of
+// structs or struct pointers. Values without an Id are ignored.
+func (t *Tracker) trackAll(v interface{}) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Struct:
+		t.Track(v)
+	case reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			if e := rv.Index(i); e.Kind() == reflect.Ptr {
+				t.Track(e.Interface())
+			} else {
+				t.Track(e.Addr().Interface())
+			}
+		}
+	}
+}
+
+// Updates the snapshot of v, if it is tracked, with the current value of
+// each of the Parse fields named in fields. The rest of the snapshot is left
+// as it was, so that local changes that have not been saved are still
+// reported.
+func (t *Tracker) refresh(v interface{}, fields []string) {
+	key, s, err := takeSnapshot(v)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev, ok := t.get(key)
+	if !ok {
+		return
+	}
+
+	ns := make(snapshot, len(prev))
+	for k, b := range prev {
+		ns[k] = b
+	}
+	for _, f := range fields {
+		if b, ok := s[f]; ok {
+			ns[f] = b
+		} else {
+			delete(ns, f)
+		}
+	}
+	t.put(key, ns)
+}
+
+type fieldChange struct {
+	name string
+	op   updateOp
+}
+
+// Computes the operations needed to bring the saved object up to date with v
+func (t *Tracker) diff(v interface{}) ([]fieldChange, error) {
+	key, current, err := takeSnapshot(v)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	prev, ok := t.get(key)
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("parse: object %s is not tracked", key)
+	}
+
+	rvi := reflect.Indirect(reflect.ValueOf(v))
+	sc := codecFor(rvi.Type())
+
+	var changes []fieldChange
+	for _, fc := range sc.fields {
+		if !fc.encode {
+			continue
+		}
+
+		old, hadOld := prev[fc.wireName]
+		cur, hasCur := current[fc.wireName]
+		switch {
+		case !hasCur && hadOld:
+			changes = append(changes, fieldChange{fc.wireName, updateOp{UpdateType: opDelete}})
+		case !hasCur, string(old) == string(cur):
+		case hadOld && fc.counter:
+			if amount, ok := counterDelta(old, fieldByIndex(rvi, fc.index, false)); ok {
+				changes = append(changes, fieldChange{fc.wireName, updateOp{UpdateType: opIncr, Value: amount}})
+				break
+			}
+			fallthrough
+		default:
+			changes = append(changes, fieldChange{fc.wireName, updateOp{UpdateType: opSet, Value: json.RawMessage(cur)}})
+		}
+	}
+	return changes, nil
+}
+
+// Returns the amount by which the numeric field v has changed from old
+func counterDelta(old []byte, v reflect.Value) (interface{}, bool) {
+	var prev float64
+	if err := json.Unmarshal(old, &prev); err != nil {
+		return nil, false
+	}
+
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() - int64(prev), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()) - int64(prev), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float() - prev, true
+	}
+	return nil, false
+}
+
+func trackingKey(v interface{}) (string, error) {
+	rvi := reflect.Indirect(reflect.ValueOf(v))
+	if rvi.Kind() != reflect.Struct {
+		return "", fmt.Errorf("parse: expected a pointer to a struct got %v", rvi.Kind())
+	}
+
+	var id string
+	if f := rvi.FieldByName("Id"); f.IsValid() && f.Kind() == reflect.String {
+		id = f.String()
+	}
+	if id == "" {
+		return "", errors.New("parse: can not track an object without an Id")
+	}
+	return getClassName(v) + "/" + id, nil
+}
+
+func takeSnapshot(v interface{}) (string, snapshot, error) {
+	key, err := trackingKey(v)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rvi := reflect.Indirect(reflect.ValueOf(v))
+	sc := codecFor(rvi.Type())
+	s := snapshot{}
+	for _, fc := range sc.fields {
+		if !fc.encode {
+			continue
+		}
+
+		fv := fieldByIndex(rvi, fc.index, false)
+		if !fv.IsValid() || fc.omitEmpty && isEmptyValue(fv) || canBeNil(fv) && fv.IsNil() {
+			continue
+		}
+
+		ev, err := encodeField(fv, fc)
+		if err != nil {
+			return "", nil, err
+		}
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return "", nil, err
+		}
+		s[fc.wireName] = b
+	}
+	return key, s, nil
+}
+
+// Tracks v if tracking is enabled and err is nil. Returns err.
+func (c *Client) track(v interface{}, err error) error {
+	if err == nil && c.tracker != nil {
+		c.tracker.Track(v)
+	}
+	return err
+}
+
+// Set the Tracker used to record snapshots of objects. Once set, objects
+// retrieved by Get, First and Find, created, or updated with this client are
+// tracked automatically, and changes to them may be saved with
+// NewTrackedUpdate. Pass nil to stop tracking.
+//
+// Objects returned by Query.Each are not tracked automatically, since Each may
+// return any number of results. Call Tracker.Track for those that should be.
+func (c *Client) SetTracker(t *Tracker) {
+	c.tracker = t
+}
+
+// Create an update request for the object pointed to by v containing only the
+// fields that have changed since it was last tracked. Fields that have become
+// nil or empty (for fields with the omitempty option) are deleted, and fields
+// with the counter option are incremented by the difference in their value.
+// All other changed fields are set.
+//
+// E.g., to save changes to a post, checking first that it has not been
+// modified by someone else since it was retrieved:
+//
+//	cli.SetTracker(parse.NewTracker(1000))
+//	post := Post{}
+//	q, _ := cli.NewQuery(&post)
+//	q.Get("abc")
+//	post.Title = "New title"
+//	u, _ := cli.NewTrackedUpdate(&post)
+//	u.IfUnmodified()
+//	err := u.Execute() // Sends {"title":"New title"}
+//
+// Executing the update does not modify v, other than setting UpdatedAt and any
+// fields returned by Parse, such as the new value of incremented fields. If
+// nothing has changed, Execute makes no request.
+func (c *Client) NewTrackedUpdate(v interface{}) (Update, error) {
+	if c.tracker == nil {
+		return nil, errors.New("parse: no tracker set")
+	}
+
+	changes, err := c.tracker.diff(v)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &updateRequest{
+		client:  c,
+		inst:    v,
+		values:  map[string]updateOp{},
+		tracked: map[string]bool{},
+	}
+	for _, ch := range changes {
+		u.values[ch.name] = ch.op
+		u.tracked[ch.name] = true
+	}
+	return u, nil
+}
+
+// Updates the tracker's snapshot of u.inst after the update has been saved,
+// for the fields that were sent and those returned by Parse in resp
+func (u *updateRequest) retrack(t *Tracker, resp []byte) {
+	sc := codecFor(reflect.Indirect(reflect.ValueOf(u.inst)).Type())
+	names := make([]string, 0, len(u.values))
+	for k := range u.values {
+		names = append(names, k)
+	}
+	returned := map[string]json.RawMessage{}
+	if err := json.Unmarshal(resp, &returned); err == nil {
+		for k := range returned {
+			names = append(names, k)
+		}
+	}
+
+	fields := make([]string, 0, len(names))
+	for _, n := range names {
+		if fc, ok := sc.lookup(n); ok {
+			fields = append(fields, fc.wireName)
+		}
+	}
+	t.refresh(u.inst, fields)
+}
+
+// Returns an error if the object pointed to by u.inst has been modified on the
+// server since the time in its UpdatedAt field.
+//
+// This is a best-effort check only. The REST API has no way to make an update
+// conditional, so the object is retrieved with a separate request, and a
+// modification made between that request and the update is not detected.
+func (u *updateRequest) checkUnmodified() error {
+	rv := reflect.ValueOf(u.inst)
+	sc := codecFor(rv.Elem().Type())
+	fc, ok := sc.lookup("updatedAt")
+	if !ok {
+		return errors.New("parse: can not check for modification - type has no UpdatedAt field")
+	}
+	updatedAt, ok := fieldByIndex(rv.Elem(), fc.index, false).Interface().(time.Time)
+	if !ok {
+		return errors.New("parse: can not check for modification - UpdatedAt should be a time.Time")
+	}
+
+	id, _ := rv.Elem().FieldByName("Id").Interface().(string)
+	current := reflect.New(rv.Elem().Type())
+	qi, err := u.client.NewQuery(current.Interface())
+	if err != nil {
+		return err
+	}
+	q := qi.(*query)
+	q.op = otGet
+	q.instId = &id
+	q.shouldUseMasterKey = u.shouldUseMasterKey
+	q.st = u.st
+	q.sess = u.sess
+	q.hdrs = u.hdrs
+	q.Keys("updatedAt")
+
+	// Bypass the query cache, which may hold a stale copy of the object
+	if b, err := u.client.doRequest(q); err != nil {
+		return err
+	} else if err := u.client.handleResponse(b, current.Interface()); err != nil {
+		return err
+	}
+
+	latest, _ := fieldByIndex(current.Elem(), fc.index, false).Interface().(time.Time)
+	if !latest.Equal(updatedAt) {
+		return ErrConcurrentModification
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package parse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type trackerTestType struct {
+	Base
+	Title  string
+	Views  int      `parse:"views,counter"`
+	Tags   []string `parse:"tags,omitempty"`
+	Author *User
+}
+
+func (t *trackerTestType) ClassName() string {
+	return "Post"
+}
+
+func TestTrackedUpdate(t *testing.T) {
+	var bodies []string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"objectId":"abc","title":"Hello","views":10,"tags":["a"],"author":{"__type":"Pointer","className":"_User","objectId":"u1"},"updatedAt":"2015-01-01T00:00:00.000Z"}`)
+		case "PUT":
+			b, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			fmt.Fprint(w, `{"views":15,"updatedAt":"2015-01-02T00:00:00.000Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetTracker(NewTracker(0))
+
+	p := trackerTestType{}
+	q, _ := cli.NewQuery(&p)
+	if err := q.Get("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if changed, err := cli.tracker.Changed(&p); err != nil || len(changed) != 0 {
+		t.Errorf("expected no changes after Get. got %v (%v)", changed, err)
+	}
+
+	p.Title = "Hello, World"
+	p.Views += 3
+	p.Tags = nil
+	p.Author = nil
+
+	changed, _ := cli.tracker.Changed(&p)
+	sort.Strings(changed)
+	if expected := []string{"author", "tags", "title", "views"}; !reflect.DeepEqual(changed, expected) {
+		t.Errorf("wrong changed fields. got %v expected %v", changed, expected)
+	}
+
+	u, err := cli.NewTrackedUpdate(&p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := u.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `{"author":{"__op":"Delete"},"tags":{"__op":"Delete"},"title":"Hello, World","views":{"__op":"Increment","amount":3}}`
+	if len(bodies) != 1 || bodies[0] != expected {
+		t.Errorf("unexpected update body.\ngot:      %v\nexpected: %s", bodies, expected)
+	}
+	if p.Views != 15 || p.Title != "Hello, World" {
+		t.Errorf("expected values returned by Parse to be applied. got %+v", p)
+	}
+
+	if changed, err := cli.tracker.Changed(&p); err != nil || len(changed) != 0 {
+		t.Errorf("expected object to be tracked again after saving. got %v (%v)", changed, err)
+	}
+
+	u, _ = cli.NewTrackedUpdate(&p)
+	if err := u.Execute(); err != nil || len(bodies) != 1 {
+		t.Errorf("expected no request to be made when nothing changed. got %v (%v)", bodies, err)
+	}
+
+	other := trackerTestType{}
+	other.Id = "other"
+	if _, err := cli.NewTrackedUpdate(&other); err == nil {
+		t.Errorf("expected an error for an untracked object")
+	}
+}
+
+func TestUpdateIfUnmodified(t *testing.T) {
+	puts := 0
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if keys := r.URL.Query().Get("keys"); keys != "updatedAt" {
+				t.Errorf("expected only updatedAt to be retrieved. got keys=%s", keys)
+			}
+			fmt.Fprint(w, `{"objectId":"abc","updatedAt":"2015-01-02T00:00:00.000Z"}`)
+		case "PUT":
+			puts++
+			fmt.Fprint(w, `{"updatedAt":"2015-01-03T00:00:00.000Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	p := trackerTestType{}
+	p.Id = "abc"
+	p.UpdatedAt, _ = parseTime("2015-01-01T00:00:00.000Z")
+
+	u, _ := testClient.NewUpdate(&p)
+	u.Set("title", "New")
+	u.IfUnmodified()
+	if err := u.Execute(); err != ErrConcurrentModification {
+		t.Errorf("expected ErrConcurrentModification. got %v", err)
+	}
+	if puts != 0 || p.Title != "" {
+		t.Errorf("expected no update to be made")
+	}
+
+	p.UpdatedAt, _ = parseTime("2015-01-02T00:00:00.000Z")
+	if err := u.Execute(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if puts != 1 || p.Title != "New" {
+		t.Errorf("expected update to be made")
+	}
+}
+
+func TestTrackerEviction(t *testing.T) {
+	tr := NewTracker(2)
+	posts := make([]trackerTestType, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		posts[i].Id = id
+	}
+
+	tr.Track(&posts[0])
+	tr.Track(&posts[1])
+	// use "a" so that "b" becomes the least recently used snapshot
+	if _, err := tr.Changed(&posts[0]); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	tr.Track(&posts[2])
+
+	if n := tr.Len(); n != 2 {
+		t.Errorf("expected tracker to hold 2 snapshots. got [%d]", n)
+	}
+	if _, err := tr.Changed(&posts[1]); err == nil {
+		t.Errorf("expected snapshot of [b] to be evicted")
+	}
+	for _, p := range []*trackerTestType{&posts[0], &posts[2]} {
+		if _, err := tr.Changed(p); err != nil {
+			t.Errorf("expected snapshot of [%s] to be present. got %v", p.Id, err)
+		}
+	}
+}
+
+func TestEachResultsNotTracked(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[{"objectId":"a","title":"one"},{"objectId":"b","title":"two"}]}`)
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetTracker(NewTracker(0))
+
+	q, _ := cli.NewQuery(&trackerTestType{})
+	rc := make(chan *trackerTestType)
+	it, err := q.Each(rc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+loop:
+	for {
+		select {
+		case <-rc:
+		case err := <-it.Done():
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			break loop
+		}
+	}
+
+	if n := cli.tracker.Len(); n != 0 {
+		t.Errorf("expected results of Each not to be tracked. got [%d] snapshots", n)
+	}
+
+	posts := []*trackerTestType{}
+	q, _ = cli.NewQuery(&posts)
+	if err := q.Find(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := cli.tracker.Len(); n != 2 {
+		t.Errorf("expected results of Find to be tracked. got [%d] snapshots", n)
+	}
+}
+
+func TestUpdateKeepsUnsavedChangesTracked(t *testing.T) {
+	var bodies []string
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"objectId":"abc","title":"Hello","views":10,"tags":["a"],"updatedAt":"2015-01-01T00:00:00.000Z"}`)
+		case "PUT":
+			b, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			fmt.Fprint(w, `{"updatedAt":"2015-01-02T00:00:00.000Z"}`)
+		}
+	})
+	defer teardownTestServer()
+
+	cli := *testClient
+	cli.SetTracker(NewTracker(0))
+
+	p := trackerTestType{}
+	q, _ := cli.NewQuery(&p)
+	if err := q.Get("abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Change tags locally, but only save the title
+	p.Tags = []string{"a", "b"}
+	u, _ := cli.NewUpdate(&p)
+	u.Set("title", "New")
+	if err := u.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if changed, err := cli.tracker.Changed(&p); err != nil || !reflect.DeepEqual(changed, []string{"tags"}) {
+		t.Errorf("expected unsaved change to tags to still be tracked. got %v (%v)", changed, err)
+	}
+
+	u, _ = cli.NewTrackedUpdate(&p)
+	if err := u.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := `{"tags":["a","b"]}`; len(bodies) != 2 || bodies[1] != expected {
+		t.Errorf("unexpected tracked update body.\ngot:      %v\nexpected: %s", bodies, expected)
+	}
+}
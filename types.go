@@ -7,6 +7,7 @@ import (
 	"math"
 	"path"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -14,7 +15,51 @@ func init() {
 	gob.Register(&acl{})
 }
 
-var registeredTypes = map[string]reflect.Type{}
+var registeredTypes = newTypeRegistry(nil)
+
+// A set of types registered by class name. See RegisterType
+type typeRegistry struct {
+	mu     sync.RWMutex
+	types  map[string]reflect.Type
+	parent *typeRegistry
+}
+
+// Create a new registry. Types not found in the new registry are looked up
+// in parent, if it is not nil
+func newTypeRegistry(parent *typeRegistry) *typeRegistry {
+	return &typeRegistry{
+		types:  map[string]reflect.Type{},
+		parent: parent,
+	}
+}
+
+func (r *typeRegistry) register(t interface{}) error {
+	rv := reflect.ValueOf(t)
+	rvi := reflect.Indirect(rv)
+
+	if rvi.Kind() != reflect.Struct {
+		return fmt.Errorf("parse: expected struct or pointer to struct, got: %v", rv.Kind())
+	}
+
+	// Compile the type's codec now rather than on first use
+	codecFor(rvi.Type())
+
+	className := getClassName(t)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[className] = rvi.Type()
+	return nil
+}
+
+func (r *typeRegistry) lookup(className string) (reflect.Type, bool) {
+	r.mu.RLock()
+	t, ok := r.types[className]
+	r.mu.RUnlock()
+	if !ok && r.parent != nil {
+		return r.parent.lookup(className)
+	}
+	return t, ok
+}
 
 // An interface for custom Parse types. Contains a single method:
 //
@@ -51,10 +96,15 @@ func getClassName(v interface{}) string {
 	}
 }
 
-func getEndpointBase(v interface{}) string {
-	var inst interface{}
-	var p string
+// Returns the class name of the type pointed to by v. If v points to a slice
+// or array, the class name of the element type is returned
+func getElemClassName(v interface{}) string {
+	return getClassName(elemInstance(v))
+}
 
+// Returns a pointer to a new value of the element type of v if v points to
+// a slice or array. Otherwise v is returned unmodified
+func elemInstance(v interface{}) interface{} {
 	rt := reflect.TypeOf(v)
 	rt = rt.Elem()
 	if rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array {
@@ -65,11 +115,15 @@ func getEndpointBase(v interface{}) string {
 		} else {
 			rv = reflect.New(rte)
 		}
-		inst = rv.Interface()
-	} else {
-		inst = v
+		return rv.Interface()
 	}
+	return v
+}
 
+func getEndpointBase(v interface{}) string {
+	var p string
+
+	inst := elemInstance(v)
 	if iv, ok := inst.(HasEndpoint); ok {
 		p = iv.Endpoint()
 	} else {
@@ -137,10 +191,131 @@ func (a *FacebookAuthData) UnmarshalJSON(b []byte) (err error) {
 	return err
 }
 
+type AppleAuthData struct {
+	Id    string `json:"id"`
+	Token string `json:"token"`
+}
+
+type GoogleAuthData struct {
+	Id          string `json:"id"`
+	IdToken     string `json:"id_token,omitempty" parse:"id_token"`
+	AccessToken string `json:"access_token,omitempty" parse:"access_token"`
+}
+
+type GitHubAuthData struct {
+	Id          string `json:"id"`
+	AccessToken string `json:"access_token" parse:"access_token"`
+}
+
+// Multi-factor authentication data, used with Parse Server's MFA adapter.
+// Token is a TOTP code or recovery code. Status is returned by Parse for users
+// with MFA enabled.
+type MFAAuthData struct {
+	Token  string `json:"token,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// Authentication data for third-party login providers, keyed by provider name
+// in Parse. Data for providers without a field here is kept in Extra, e.g.
+// Extra["linkedin"].
 type AuthData struct {
-	Twitter   *TwitterAuthData   `json:"twitter,omitempty"`
-	Facebook  *FacebookAuthData  `json:"facebook,omitempty"`
-	Anonymous *AnonymousAuthData `json:"anonymous,omitempty"`
+	Twitter   *TwitterAuthData       `json:"twitter,omitempty"`
+	Facebook  *FacebookAuthData      `json:"facebook,omitempty"`
+	Anonymous *AnonymousAuthData     `json:"anonymous,omitempty"`
+	Apple     *AppleAuthData         `json:"apple,omitempty"`
+	Google    *GoogleAuthData        `json:"google,omitempty"`
+	GitHub    *GitHubAuthData        `json:"github,omitempty"`
+	MFA       *MFAAuthData           `json:"mfa,omitempty"`
+	Extra     map[string]interface{} `json:"-"`
+}
+
+func (a AuthData) MarshalJSON() ([]byte, error) {
+	type authData AuthData
+	b, err := json.Marshal(authData(a))
+	if err != nil || len(a.Extra) == 0 {
+		return b, err
+	}
+
+	m := make(map[string]interface{}, len(a.Extra))
+	for k, v := range a.Extra {
+		m[k] = v
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (a *AuthData) UnmarshalJSON(b []byte) error {
+	type authData AuthData
+	known := authData{}
+	if err := json.Unmarshal(b, &known); err != nil {
+		return err
+	}
+
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	for _, p := range []string{"twitter", "facebook", "anonymous", "apple", "google", "github", "mfa"} {
+		delete(all, p)
+	}
+	if len(all) > 0 {
+		known.Extra = all
+	}
+
+	*a = AuthData(known)
+	return nil
+}
+
+// Populates a from auth data in a Parse response. Provider names are kept as
+// they are, rather than being capitalized as keys of Base.Extra are.
+func (a *AuthData) UnmarshalParse(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, a)
+}
+
+// Applies the auth data v, as sent in an update, to a. Parse merges the
+// providers in an update with those already linked, removing any whose data
+// is null, so only the providers present in v are replaced.
+func (a *AuthData) merge(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	update := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &update); err != nil {
+		return err
+	}
+
+	if b, err = json.Marshal(a); err != nil {
+		return err
+	}
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return err
+	}
+	for p, d := range update {
+		if string(d) == "null" {
+			delete(merged, p)
+		} else {
+			merged[p] = d
+		}
+	}
+
+	if b, err = json.Marshal(merged); err != nil {
+		return err
+	}
+	na := AuthData{}
+	if err := json.Unmarshal(b, &na); err != nil {
+		return err
+	}
+	*a = na
+	return nil
 }
 
 // Represents the built-in Parse "User" class. Embed this type in a custom
@@ -203,6 +378,26 @@ func (r *Role) Endpoint() string {
 	return "roles"
 }
 
+// Represents the built-in Parse "Session" class. Sessions are created by Parse
+// when a user signs up or logs in, and deleting one logs the user out of it.
+type SessionObject struct {
+	Base
+	SessionToken   string                 `parse:",readonly"`
+	User           *User                  `parse:",readonly"`
+	CreatedWith    map[string]interface{} `parse:",readonly"`
+	InstallationId string                 `parse:",omitempty"`
+	ExpiresAt      time.Time              `parse:",readonly"`
+	Restricted     bool                   `parse:",readonly"`
+}
+
+func (s *SessionObject) ClassName() string {
+	return "_Session"
+}
+
+func (s *SessionObject) Endpoint() string {
+	return "sessions"
+}
+
 type ACL interface {
 	// Returns whether public read access is enabled on this ACL
 	PublicReadAccess() bool
@@ -747,6 +942,7 @@ func (c Config) Map(key string) Config {
 }
 
 type configRequest struct {
+	st string
 }
 
 func (c *configRequest) method() string {
@@ -766,7 +962,7 @@ func (c *configRequest) useMasterKey() bool {
 }
 
 func (c *configRequest) sessionToken() string {
-	return ""
+	return c.st
 }
 
 func (c *configRequest) contentType() string {
@@ -774,7 +970,11 @@ func (c *configRequest) contentType() string {
 }
 
 func (c *Client) GetConfig() (Config, error) {
-	b, err := c.doRequest(&configRequest{})
+	return c.getConfig("")
+}
+
+func (c *Client) getConfig(st string) (Config, error) {
+	b, err := c.doRequest(&configRequest{st: st})
 	if err != nil {
 		return nil, err
 	}
@@ -807,27 +1007,41 @@ func (c *Client) GetConfig() (Config, error) {
 // Accepts a value t, representing the type to be registered. The value
 // t should be either a struct value, or a pointer to a struct. Otherwise,
 // an error will be returned.
+//
+// Types registered with this function are available to all clients. Use
+// Client.RegisterType to register a type with a single client.
 func RegisterType(t interface{}) error {
-	rv := reflect.ValueOf(t)
-	rvi := reflect.Indirect(rv)
+	return registeredTypes.register(t)
+}
 
-	if rvi.Kind() != reflect.Struct {
-		return fmt.Errorf("parse: expected struct or pointer to struct, got: %v", rv.Kind())
+// Register a type for use when populating values from responses to requests
+// made by this client. See RegisterType for details.
+//
+// Types registered with a client take precedence over those registered with
+// the package level RegisterType function. This allows clients for different
+// apps to register different types under the same class name.
+func (c *Client) RegisterType(t interface{}) error {
+	if c.types == nil {
+		c.types = newTypeRegistry(registeredTypes)
 	}
-
-	className := getClassName(t)
-	registeredTypes[className] = rvi.Type()
-	return nil
+	return c.types.register(t)
 }
 
 // Transform the given value into the proper representation for Marshaling as part
 // of a request
 //
-// E.g. A struct is turned into a Pointer type, a time.Time is turned into a Date
+// E.g. A struct is turned into a Pointer type, a time.Time is turned into a Date.
+// Values implementing Marshaler are encoded with MarshalParse.
 func encodeForRequest(v interface{}) interface{} {
 	if v == nil {
 		return nil
 	}
+	if m, ok := v.(Marshaler); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		return &marshaledValue{m: m}
+	}
 	rv := reflect.ValueOf(v)
 	rvi := reflect.Indirect(rv)
 	rt := rvi.Type()
@@ -855,8 +1069,7 @@ func encodeForRequest(v interface{}) interface{} {
 		case ACL, *ACL:
 			return v
 		case AuthData, *AuthData:
-			b, _ := json.Marshal(v)
-			return string(b)
+			return v
 		default:
 			var cname string
 
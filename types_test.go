@@ -377,7 +377,7 @@ func TestRegisterType(t *testing.T) {
 func TestPopulateAcl(t *testing.T) {
 	body := `{"ACL":{"*":{"read":true},"abc":{"read":true},"def":{"read":true,"write":true},"role:xyz":{"read":true}}}`
 	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, body)
+		fmt.Fprint(w, body)
 	})
 	defer teardownTestServer()
 
@@ -422,3 +422,59 @@ func TestPopulateAcl(t *testing.T) {
 		t.Errorf("Acl was different from expected. Got[%v] Expected[%v]\n", actual, expected)
 	}
 }
+
+type RegistryTestA struct {
+	Name string
+}
+
+func (r *RegistryTestA) ClassName() string {
+	return "Shared"
+}
+
+type RegistryTestB struct {
+	Title string
+}
+
+func (r *RegistryTestB) ClassName() string {
+	return "Shared"
+}
+
+func TestClientRegisterType(t *testing.T) {
+	setupTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"f1":{"__type":"Object","className":"Shared","name":"a","title":"b"}}`)
+	})
+	defer teardownTestServer()
+
+	type TestType struct {
+		F1 interface{}
+	}
+
+	newClient := func() *Client {
+		c := NewClient("app_id", "rest_key", "master_key", testClient.host, testClient.path)
+		c.SetHTTPClient(testClient.httpClient)
+		return c
+	}
+
+	c1 := newClient()
+	c1.RegisterType(new(RegistryTestA))
+	c2 := newClient()
+	c2.RegisterType(new(RegistryTestB))
+
+	tt1 := TestType{}
+	q1, _ := c1.NewQuery(&tt1)
+	if err := q1.Get("123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := tt1.F1.(*RegistryTestA); !ok || v.Name != "a" {
+		t.Errorf("expected F1 to be a *RegistryTestA. got: %#v", tt1.F1)
+	}
+
+	tt2 := TestType{}
+	q2, _ := c2.NewQuery(&tt2)
+	if err := q2.Get("123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := tt2.F1.(*RegistryTestB); !ok || v.Title != "b" {
+		t.Errorf("expected F1 to be a *RegistryTestB. got: %#v", tt2.F1)
+	}
+}
@@ -106,6 +106,21 @@ type Update interface {
 	// Set the session token for the given request.
 	SetSessionToken(st string)
 
+	// Set headers to send with this update, in addition to those set for the
+	// client
+	SetHeaders(h RequestHeaders)
+
+	// Fail with ErrConcurrentModification if the object has been modified
+	// on the server since the time in its UpdatedAt field.
+	//
+	// This check is best-effort only and does not prevent lost updates. The
+	// REST API can not make an update conditional, so the check is made with
+	// a separate request before the update is sent. A modification made
+	// between the two requests is overwritten without an error. Use a
+	// beforeSave trigger in Cloud Code where a conflicting write must be
+	// rejected.
+	IfUnmodified()
+
 	// Execute this update. This method also updates the proper fields
 	// on the provided value with their repective new values
 	Execute() error
@@ -119,7 +134,14 @@ type updateRequest struct {
 	inst               interface{}
 	values             map[string]updateOp
 	st                 string
+	sess               *session
+	hdrs               RequestHeaders
 	shouldUseMasterKey bool
+	ifUnmodified       bool
+
+	// Fields whose values were taken from the current state of inst, e.g. by
+	// a Tracker, and so are not applied to inst
+	tracked map[string]bool
 }
 
 // Create a new update request for the Parse object represented by v.
@@ -178,26 +200,44 @@ func (u *updateRequest) Execute() (err error) {
 		}
 	}()
 
+	if u.tracked != nil && len(u.values) == 0 {
+		return nil
+	}
+	if u.ifUnmodified {
+		if err := u.checkUnmodified(); err != nil {
+			return err
+		}
+	}
+
 	rv := reflect.ValueOf(u.inst)
 	rvi := reflect.Indirect(rv)
-	fieldMap := getFieldNameMap(rv)
+	sc := codecFor(rvi.Type())
 
 	for k, v := range u.values {
-		var fname string
-		if fn, ok := fieldMap[k]; ok {
-			fname = fn
-		} else {
-			fname = k
+		if u.tracked[k] {
+			continue
+		}
+		fc, ok := sc.lookup(k)
+		if !ok || fc.readOnly {
+			continue
 		}
-		fname = firstToUpper(fname)
 		dv := reflect.ValueOf(v.Value)
 		dvi := reflect.Indirect(dv)
 
-		if fv := rvi.FieldByName(fname); fv.IsValid() {
+		if fv := fieldByIndex(rvi, fc.index, true); fv.IsValid() {
 			fvi := reflect.Indirect(fv)
 
 			switch v.UpdateType {
 			case opSet:
+				// Auth data is merged with the providers already linked, as
+				// Parse does
+				if a, ok := authDataField(fv, v.Value); ok {
+					if err := a.merge(v.Value); err != nil {
+						return err
+					}
+					break
+				}
+
 				if fv.Kind() == reflect.Ptr && fv.IsNil() && v.Value != nil {
 					fv.Set(reflect.New(fv.Type().Elem()))
 				}
@@ -212,7 +252,7 @@ func (u *updateRequest) Execute() (err error) {
 				} else {
 					tmp = fv.Addr()
 				}
-				if err := populateValue(tmp.Interface(), v.Value); err != nil {
+				if err := u.client.populateValue(tmp.Interface(), v.Value); err != nil {
 					return err
 				}
 			case opIncr:
@@ -247,16 +287,50 @@ func (u *updateRequest) Execute() (err error) {
 	if b, err := u.client.doRequest(u); err != nil {
 		return err
 	} else {
-		return handleResponse(b, u.inst)
+		u.client.invalidateCache(u.inst)
+		if err := u.client.handleResponse(b, u.inst); err != nil {
+			return err
+		}
+		if t := u.client.tracker; t != nil {
+			u.retrack(t, b)
+		}
+		return nil
 	}
 }
 
+// Returns the AuthData held by the field fv, allocating it if fv is a nil
+// pointer, if fv is of type AuthData or *AuthData and v is not nil
+func authDataField(fv reflect.Value, v interface{}) (*AuthData, bool) {
+	if v == nil {
+		return nil, false
+	}
+	switch fv.Type() {
+	case reflect.TypeOf(AuthData{}):
+		return fv.Addr().Interface().(*AuthData), true
+	case reflect.TypeOf(&AuthData{}):
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fv.Interface().(*AuthData), true
+	}
+	return nil, false
+}
+
 func (u *updateRequest) UseMasterKey() {
 	u.shouldUseMasterKey = true
 }
 
 func (u *updateRequest) SetSessionToken(st string) {
 	u.st = st
+	u.sess = nil
+}
+
+func (u *updateRequest) SetHeaders(h RequestHeaders) {
+	u.hdrs = h
+}
+
+func (u *updateRequest) IfUnmodified() {
+	u.ifUnmodified = true
 }
 
 func (u *updateRequest) method() string {
@@ -281,7 +355,19 @@ func (u *updateRequest) endpoint() (string, error) {
 }
 
 func (u *updateRequest) body() (string, error) {
-	b, err := json.Marshal(u.values)
+	values := u.values
+	if rvi := reflect.Indirect(reflect.ValueOf(u.inst)); rvi.Kind() == reflect.Struct {
+		// Fields with the readonly option are never sent
+		sc := codecFor(rvi.Type())
+		values = make(map[string]updateOp, len(u.values))
+		for k, v := range u.values {
+			if fc, ok := sc.lookup(k); !ok || !fc.readOnly {
+				values[k] = v
+			}
+		}
+	}
+
+	b, err := json.Marshal(values)
 	if err != nil {
 		return "", err
 	}
@@ -293,9 +379,20 @@ func (u *updateRequest) useMasterKey() bool {
 }
 
 func (u *updateRequest) sessionToken() string {
+	if u.sess != nil {
+		return u.sess.currentToken()
+	}
 	return u.st
 }
 
+func (u *updateRequest) boundSession() *session {
+	return u.sess
+}
+
+func (u *updateRequest) headers() RequestHeaders {
+	return u.hdrs
+}
+
 func (u *updateRequest) contentType() string {
 	return "application/json"
 }
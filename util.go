@@ -1,6 +1,8 @@
 package parse
 
 import (
+	"crypto/rand"
+	"fmt"
 	"reflect"
 	"strings"
 	"time"
@@ -15,6 +17,26 @@ func firstToUpper(s string) string {
 	return string(unicode.ToUpper(rune(s[0]))) + s[1:]
 }
 
+// Returns n cryptographically secure random bytes
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
 // Returns the provided string with the first letter lower-cased
 func firstToLower(s string) string {
 	if len(s) < 1 {
@@ -24,16 +46,33 @@ func firstToLower(s string) string {
 }
 
 // parses struct tags in the format:
-// parse:"name,option"
+// parse:"name,option1,option2"
 //
-// and returns each component
-func parseTag(tag string) (name, options string) {
-	parts := strings.Split(tag, ",")
-	if len(parts) > 1 {
-		return parts[0], parts[1]
-	} else {
-		return parts[0], ""
+// and returns the name and options
+func parseTag(tag string) (name string, options tagOptions) {
+	if i := strings.Index(tag, ","); i != -1 {
+		return tag[:i], tagOptions(tag[i+1:])
 	}
+	return tag, ""
+}
+
+// The comma-separated options following the name in a parse struct tag
+type tagOptions string
+
+// Returns whether the option opt is present
+func (o tagOptions) Contains(opt string) bool {
+	for o != "" {
+		var next string
+		s := string(o)
+		if i := strings.Index(s, ","); i != -1 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == opt {
+			return true
+		}
+		o = tagOptions(next)
+	}
+	return false
 }
 
 func parseTime(s string) (time.Time, error) {